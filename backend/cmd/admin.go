@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+// tokenAuth allows request only with "Authorization: Bearer <token>" header,
+// endpoints are disabled when token isn't configured
+func tokenAuth(getToken func() rcon.Secret) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := getToken()
+			if token == "" {
+				http.Error(w, "Endpoint is disabled", http.StatusForbidden)
+				return
+			}
+			auth := r.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			provided := []byte(strings.TrimPrefix(auth, "Bearer "))
+			if subtle.ConstantTimeCompare(provided, []byte(token)) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var adminAuth = tokenAuth(func() rcon.Secret {
+	return getConfig().AdminToken
+})
+
+var chatAuth = tokenAuth(func() rcon.Secret {
+	if chat := getConfig().Chat; chat != nil {
+		return chat.Token
+	}
+	return ""
+})
+
+func adminConfig(w http.ResponseWriter, r *http.Request) {
+	// secrets are redacted during marshaling
+	json, err := json.Marshal(getConfig())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(json)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const apiPrefix = "/api/v1"
+
+// Cache-Control values of routes
+const (
+	cacheNone  = "no-store"
+	cacheLive  = "public, max-age=5"
+	cacheShort = "public, max-age=60"
+	cacheLong  = "public, max-age=3600"
+)
+
+const (
+	requestIDHeader     = "X-Request-ID"
+	corsAllowMethods    = "GET, POST, PUT, DELETE"
+	corsAllowHeaders    = "Authorization, Content-Type, If-None-Match, X-Request-ID"
+	corsExposeHeaders   = "Etag, X-Total-Count, X-Request-ID"
+	maxErrorMessageSize = 4096
+)
+
+// requestIDRe limits request ids accepted from clients
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type CORSConfig struct {
+	// allowed origins like https://example.com, * allows any origin
+	Origins []string `json:"origins" yaml:"origins"`
+	// seconds preflight response can be cached
+	MaxAge int `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+}
+
+// APIError is error of /api/v1 response
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type requestIDKey struct{}
+
+// requestID returns id of request assigned by withRequestID
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID reuses X-Request-ID from client or generates new one,
+// id is returned in response header
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRe.MatchString(id) {
+			var buf [12]byte
+			rand.Read(buf[:])
+			id = hex.EncodeToString(buf[:])
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func cacheControl(value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func corsOriginAllowed(origins []string, origin string) bool {
+	for _, allowed := range origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// cors allows cross origin requests from configured origins and answers preflight requests
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf := getConfig().CORS
+		if conf == nil || len(conf.Origins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" || !corsOriginAllowed(conf.Origins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			if conf.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(conf.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errorWriter catches plain text errors written by http.Error
+type errorWriter struct {
+	http.ResponseWriter
+	status  int
+	message bytes.Buffer
+}
+
+func (w *errorWriter) WriteHeader(status int) {
+	if status >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.status = status
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *errorWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.message.Len() < maxErrorMessageSize {
+		w.message.Write(data)
+	}
+	return len(data), nil
+}
+
+// errorCode converts status to code like not_found
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// jsonErrors converts plain text errors into {"error":{"code","message"}} envelope
+func jsonErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &errorWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		if ew.status == 0 {
+			return
+		}
+		body, _ := json.Marshal(struct {
+			Error APIError `json:"error"`
+		}{APIError{errorCode(ew.status), strings.TrimSpace(ew.message.String())}})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", cacheNone)
+		w.WriteHeader(ew.status)
+		w.Write(body)
+	})
+}
+
+// deprecated marks routes mounted at root, they are aliases of /api/v1 routes
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+apiPrefix+r.URL.EscapedPath()+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
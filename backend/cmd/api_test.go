@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+func serveTest(method, target string, header http.Header) *httptest.ResponseRecorder {
+	config.Store(&Config{
+		Servers: map[string]rcon.ServerConfig{"ctf": {Server: "127.0.0.1", Port: 26000}},
+		CORS:    &CORSConfig{Origins: []string{"https://example.com"}, MaxAge: 600},
+	})
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	webService().ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIErrorEnvelope(t *testing.T) {
+	var envelope struct {
+		Error APIError `json:"error"`
+	}
+
+	w := serveTest("GET", "/api/v1/servers/missing/status", nil)
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatal("Incorrect response ", w.Code, w.Header())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Error.Code != "not_found" || envelope.Error.Message != "Server not found" {
+		t.Error("Incorrect error ", envelope.Error)
+	}
+	validateOpenAPI(t, "ErrorResponse", json.RawMessage(w.Body.Bytes()))
+	if w.Header().Get("Cache-Control") != cacheNone {
+		t.Error("Errors shouldn't be cached ", w.Header().Get("Cache-Control"))
+	}
+
+	// unknown routes use envelope too
+	w = serveTest("GET", "/api/v1/unknown", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil || envelope.Error.Code != "not_found" {
+		t.Error("Incorrect unknown route response ", w.Body.String())
+	}
+}
+
+func TestAPIDeprecatedAlias(t *testing.T) {
+	w := serveTest("GET", "/servers/missing/status", nil)
+	if w.Code != http.StatusNotFound || w.Body.String() != "Server not found\n" {
+		t.Error("Alias should return plain text error ", w.Body.String())
+	}
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") != `</api/v1/servers/missing/status>; rel="successor-version"` {
+		t.Error("Incorrect deprecation headers ", w.Header())
+	}
+
+	w = serveTest("GET", "/api/v1/servers", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" || w.Header().Get("Cache-Control") != cacheShort {
+		t.Error("Incorrect response ", w.Code, w.Header())
+	}
+}
+
+func TestAPIRequestID(t *testing.T) {
+	w := serveTest("GET", "/api/v1/healthz", http.Header{"X-Request-Id": {"abc-123"}})
+	if w.Header().Get(requestIDHeader) != "abc-123" {
+		t.Error("Request id wasn't reused ", w.Header().Get(requestIDHeader))
+	}
+	w = serveTest("GET", "/api/v1/healthz", http.Header{"X-Request-Id": {"bad id\n"}})
+	if id := w.Header().Get(requestIDHeader); len(id) != 24 {
+		t.Error("Invalid request id wasn't replaced ", id)
+	}
+}
+
+func TestAPICORS(t *testing.T) {
+	preflight := http.Header{
+		"Origin":                        {"https://example.com"},
+		"Access-Control-Request-Method": {"PUT"},
+	}
+	w := serveTest("OPTIONS", "/api/v1/servers/ctf/rotation", preflight)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" ||
+		w.Header().Get("Access-Control-Allow-Methods") != corsAllowMethods || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Error("Incorrect preflight response ", w.Code, w.Header())
+	}
+
+	w = serveTest("GET", "/api/v1/servers", http.Header{"Origin": {"https://evil.com"}})
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Vary") != "Origin" {
+		t.Error("Origin shouldn't be allowed ", w.Header())
+	}
+
+	w = serveTest("GET", "/servers", http.Header{"Origin": {"https://example.com"}})
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("CORS should be enabled only for /api/v1")
+	}
+}
@@ -0,0 +1,484 @@
+// apigen generates types and methods of pkg/client from OpenAPI specification,
+// it supports only subset of OpenAPI used by cmd/openapi.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var specPath = flag.String("spec", "openapi.json", "OpenAPI specification")
+var outPath = flag.String("out", "client_gen.go", "Output file")
+var packageName = flag.String("package", "client", "Package name")
+
+const (
+	schemaPrefix    = "#/components/schemas/"
+	parameterPrefix = "#/components/parameters/"
+)
+
+type Schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Description string             `json:"description"`
+	Properties  map[string]*Schema `json:"properties"`
+	Required    []string           `json:"required"`
+	Items       *Schema            `json:"items"`
+	AllOf       []*Schema          `json:"allOf"`
+	OneOf       []*Schema          `json:"oneOf"`
+	// AdditionalProperties is either boolean or schema
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Summary     string       `json:"summary"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]MediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]*Response `json:"responses"`
+}
+
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+	Get        *Operation   `json:"get"`
+	Put        *Operation   `json:"put"`
+	Post       *Operation   `json:"post"`
+	Delete     *Operation   `json:"delete"`
+}
+
+type Spec struct {
+	Paths      map[string]*PathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*Parameter `json:"parameters"`
+	} `json:"components"`
+}
+
+var initialisms = map[string]string{
+	"api":  "API",
+	"cpu":  "CPU",
+	"id":   "ID",
+	"idfp": "IDFP",
+	"ip":   "IP",
+	"uri":  "URI",
+	"url":  "URL",
+}
+
+// goName converts snake_case or camelCase name to exported go name
+func goName(name string) string {
+	var result strings.Builder
+
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if initialism, ok := initialisms[word]; ok {
+			result.WriteString(initialism)
+		} else {
+			result.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return result.String()
+}
+
+type generator struct {
+	spec    *Spec
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// comment writes "name is description" doc comment
+func (g *generator) comment(name, description string) {
+	if description == "" {
+		return
+	}
+	// keep case of abbreviations
+	if len(description) < 2 || !unicode.IsUpper(rune(description[1])) {
+		description = strings.ToLower(description[:1]) + description[1:]
+	}
+	g.printf("// %s is %s\n", name, description)
+}
+
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, schemaPrefix)
+}
+
+// isStruct reports whether schema is generated as struct
+func (g *generator) isStruct(schema *Schema) bool {
+	if schema.Ref != "" {
+		return g.isStruct(g.spec.Components.Schemas[refName(schema.Ref)])
+	}
+	return len(schema.AllOf) > 0 || schema.Properties != nil
+}
+
+// goType returns type of schema, structs are returned by pointer when ptr is set
+func (g *generator) goType(schema *Schema, ptr bool) (string, error) {
+	if len(schema.AllOf) == 1 {
+		// nullable reference
+		schema = schema.AllOf[0]
+	}
+	if schema.Ref != "" {
+		name := refName(schema.Ref)
+		if _, ok := g.spec.Components.Schemas[name]; !ok {
+			return "", fmt.Errorf("Unknown schema %s", schema.Ref)
+		}
+		if ptr && g.isStruct(schema) {
+			return "*" + name, nil
+		}
+		return name, nil
+	}
+	if len(schema.OneOf) > 0 {
+		g.imports["encoding/json"] = true
+		return "json.RawMessage", nil
+	}
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			g.imports["time"] = true
+			if ptr {
+				return "*time.Time", nil
+			}
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		if schema.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("Array without items")
+		}
+		item, err := g.goType(schema.Items, false)
+		return "[]" + item, err
+	case "object":
+		if schema.Properties != nil {
+			return "", fmt.Errorf("Inline objects aren't supported")
+		}
+		var value Schema
+		if err := json.Unmarshal(schema.AdditionalProperties, &value); err != nil || value.Type == "" && value.Ref == "" {
+			return "map[string]interface{}", nil
+		}
+		item, err := g.goType(&value, false)
+		return "map[string]" + item, err
+	}
+	return "", fmt.Errorf("Unsupported schema type %q", schema.Type)
+}
+
+func (g *generator) fields(schema *Schema) error {
+	var names []string
+
+	required := make(map[string]bool)
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property := schema.Properties[name]
+		// structs are pointers, optional time is pointer too, otherwise omitempty doesn't work
+		fieldType, err := g.goType(property, !required[name] || property.Type != "string")
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		switch property.Type {
+		case "boolean", "integer", "number":
+			// zero value of optional field should be sent
+			if !required[name] {
+				fieldType = "*" + fieldType
+			}
+		}
+		tag := name
+		if !required[name] {
+			tag += ",omitempty"
+		}
+		g.comment(goName(name), property.Description)
+		g.printf("%s %s `json:\"%s\"`\n", goName(name), fieldType, tag)
+	}
+	return nil
+}
+
+func (g *generator) schema(name string, schema *Schema) error {
+	g.comment(name, schema.Description)
+	switch {
+	case len(schema.AllOf) > 0:
+		g.printf("type %s struct {\n", name)
+		for _, part := range schema.AllOf {
+			if part.Ref != "" {
+				g.printf("%s\n", refName(part.Ref))
+			} else if err := g.fields(part); err != nil {
+				return err
+			}
+		}
+		g.printf("}\n\n")
+	case schema.Properties != nil:
+		g.printf("type %s struct {\n", name)
+		if err := g.fields(schema); err != nil {
+			return err
+		}
+		g.printf("}\n\n")
+	default:
+		goType, err := g.goType(schema, false)
+		if err != nil {
+			return err
+		}
+		g.printf("type %s %s\n\n", name, goType)
+	}
+	return nil
+}
+
+func (g *generator) parameter(param *Parameter) *Parameter {
+	if param.Ref != "" {
+		return g.spec.Components.Parameters[strings.TrimPrefix(param.Ref, parameterPrefix)]
+	}
+	return param
+}
+
+func (g *generator) params(name string, params []*Parameter) error {
+	g.printf("// %s are query parameters of %s\n", name+"Params", name)
+	g.printf("type %sParams struct {\n", name)
+	for _, param := range params {
+		g.comment(goName(param.Name), param.Description)
+		switch param.Schema.Type {
+		case "string":
+			g.printf("%s string\n", goName(param.Name))
+		case "integer":
+			g.printf("%s *int64\n", goName(param.Name))
+		case "array":
+			g.printf("%s []string\n", goName(param.Name))
+		default:
+			return fmt.Errorf("Unsupported parameter type %s", param.Schema.Type)
+		}
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+func (g *generator) operation(method, path string, item *PathItem, op *Operation) error {
+	var pathParams, queryParams []*Parameter
+	var args []string
+
+	name := goName(op.OperationID)
+	for _, param := range append(append([]*Parameter{}, item.Parameters...), op.Parameters...) {
+		param = g.parameter(param)
+		if param == nil {
+			return fmt.Errorf("%s: unknown parameter", name)
+		}
+		if param.In == "path" {
+			pathParams = append(pathParams, param)
+		} else if param.In == "query" {
+			queryParams = append(queryParams, param)
+		}
+	}
+	sort.SliceStable(pathParams, func(i, j int) bool {
+		return strings.Index(path, "{"+pathParams[i].Name+"}") < strings.Index(path, "{"+pathParams[j].Name+"}")
+	})
+	if len(queryParams) > 0 {
+		if err := g.params(name, queryParams); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	args = append(args, "ctx context.Context")
+	pathExpr := fmt.Sprintf("%q", path)
+	for _, param := range pathParams {
+		args = append(args, param.Name+" string")
+		pathExpr = strings.Replace(pathExpr, "{"+param.Name+"}", `"+url.PathEscape(`+param.Name+`)+"`, 1)
+	}
+	pathExpr = strings.TrimSuffix(pathExpr, `+""`)
+	bodyExpr := "nil"
+	if op.RequestBody != nil {
+		media, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			return fmt.Errorf("%s: only json body is supported", name)
+		}
+		bodyType, err := g.goType(media.Schema, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		args = append(args, "body "+bodyType)
+		bodyExpr = "body"
+	}
+	queryExpr := "nil"
+	if len(queryParams) > 0 {
+		args = append(args, "params *"+name+"Params")
+		queryExpr = "query"
+	}
+
+	// result is decoded from first successful response
+	var codes []string
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	var response *Response
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			response = op.Responses[code]
+			break
+		}
+	}
+	if response == nil {
+		return fmt.Errorf("%s: successful response is missing", name)
+	}
+	resultType := ""
+	raw := false
+	if media, ok := response.Content["application/json"]; ok {
+		var err error
+		resultType, err = g.goType(media.Schema, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	} else if len(response.Content) > 0 {
+		resultType = "[]byte"
+		raw = true
+	}
+
+	g.printf("// %s requests %s %s\n", name, strings.ToUpper(method), path)
+	if op.Summary != "" {
+		g.printf("//\n// %s\n", op.Summary)
+	}
+	returns := "error"
+	if resultType != "" {
+		returns = "(" + resultType + ", error)"
+	}
+	g.printf("func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), returns)
+	if len(queryParams) > 0 {
+		g.printf("query := make(url.Values)\n")
+		g.printf("if params != nil {\n")
+		for _, param := range queryParams {
+			field := "params." + goName(param.Name)
+			switch param.Schema.Type {
+			case "string":
+				g.printf("if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, param.Name, field)
+			case "integer":
+				g.imports["strconv"] = true
+				g.printf("if %s != nil {\nquery.Set(%q, strconv.FormatInt(*%s, 10))\n}\n", field, param.Name, field)
+			case "array":
+				g.printf("for _, value := range %s {\nquery.Add(%q, value)\n}\n", field, param.Name)
+			}
+		}
+		g.printf("}\n")
+	}
+	call := fmt.Sprintf("ctx, %q, %s, %s, %s", strings.ToUpper(method), pathExpr, queryExpr, bodyExpr)
+	switch {
+	case resultType == "":
+		g.printf("return c.do(%s, nil)\n", call)
+	case raw:
+		g.printf("return c.doRaw(%s)\n", call)
+	case strings.HasPrefix(resultType, "*"):
+		g.printf("var result %s\n", resultType[1:])
+		g.printf("if err := c.do(%s, &result); err != nil {\nreturn nil, err\n}\n", call)
+		g.printf("return &result, nil\n")
+	default:
+		g.printf("var result %s\n", resultType)
+		g.printf("err := c.do(%s, &result)\n", call)
+		g.printf("return result, err\n")
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+func generate(data []byte, pkg string) ([]byte, error) {
+	var spec Spec
+	var names, paths []string
+
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	g := &generator{spec: &spec, imports: map[string]bool{"context": true, "net/url": true}}
+	for name := range spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.schema(name, spec.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		item := spec.Paths[path]
+		operations := []struct {
+			method string
+			op     *Operation
+		}{
+			{http.MethodGet, item.Get},
+			{http.MethodPut, item.Put},
+			{http.MethodPost, item.Post},
+			{http.MethodDelete, item.Delete},
+		}
+		for _, operation := range operations {
+			if operation.op == nil {
+				continue
+			}
+			if err := g.operation(operation.method, path, item, operation.op); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var imports []string
+	for name := range g.imports {
+		imports = append(imports, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(imports)
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by apigen from openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n%s\n)\n\n", pkg, strings.Join(imports, "\n"))
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+func main() {
+	flag.Parse()
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	source, err := generate(data, *packageName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*outPath, source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"players_count":   "PlayersCount",
+		"id":              "ID",
+		"getServerStatus": "GetServerStatus",
+		"offset_avg":      "OffsetAvg",
+		"team_id":         "TeamID",
+	}
+	for name, expected := range tests {
+		if result := goName(name); result != expected {
+			t.Errorf("Incorrect name for %s: %s", name, result)
+		}
+	}
+}
+
+func TestClientUpToDate(t *testing.T) {
+	spec, err := os.ReadFile("../openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := generate(spec, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := os.ReadFile("../../pkg/client/client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, current) {
+		t.Error("pkg/client is outdated, run go generate ./pkg/client/")
+	}
+}
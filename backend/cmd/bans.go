@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+)
+
+var errBanListDisabled = errors.New("Shared ban list isn't configured")
+
+// SharedBan is stored in persistent ban list and applied to all servers
+type SharedBan struct {
+	Address string    `json:"address"`
+	Reason  string    `json:"reason,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+type BanRequest struct {
+	Address  string `json:"address"`
+	Duration int64  `json:"duration"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type BanList struct {
+	path       func() string
+	lock       sync.Mutex
+	loadedPath string
+	bans       []SharedBan
+}
+
+// load reads ban list when path is changed, lock should be held
+func (l *BanList) load() error {
+	var bans []SharedBan
+
+	path := l.path()
+	if path == "" {
+		return errBanListDisabled
+	}
+	if path == l.loadedPath {
+		return nil
+	}
+	err := readJSONFile(path, &bans)
+	if err != nil {
+		return err
+	}
+	l.bans = bans
+	l.loadedPath = path
+	return nil
+}
+
+// save writes ban list, lock should be held
+func (l *BanList) save() error {
+	return writeJSONFile(l.loadedPath, l.bans)
+}
+
+// removeExpired drops expired bans, lock should be held
+func (l *BanList) removeExpired() {
+	now := time.Now()
+	bans := l.bans[:0]
+	for _, ban := range l.bans {
+		if ban.Expires.After(now) {
+			bans = append(bans, ban)
+		}
+	}
+	l.bans = bans
+}
+
+func (l *BanList) List() ([]SharedBan, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	l.removeExpired()
+	result := make([]SharedBan, len(l.bans))
+	copy(result, l.bans)
+	return result, nil
+}
+
+// Add stores ban, existing ban for the same address is replaced
+func (l *BanList) Add(ban SharedBan) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.load(); err != nil {
+		return err
+	}
+	l.removeExpired()
+	for i := range l.bans {
+		if l.bans[i].Address == ban.Address {
+			l.bans[i] = ban
+			return l.save()
+		}
+	}
+	l.bans = append(l.bans, ban)
+	return l.save()
+}
+
+func (l *BanList) Remove(address string) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.load(); err != nil {
+		return false, err
+	}
+	for i := range l.bans {
+		if l.bans[i].Address == address {
+			l.bans = append(l.bans[:i], l.bans[i+1:]...)
+			return true, l.save()
+		}
+	}
+	return false, nil
+}
+
+func queryBans(serverConf *rcon.ServerConfig) ([]rcon.Ban, error) {
+	return rcon.QueryWithRetries(time.Millisecond*1000, 3,
+		func(deadline time.Time) ([]rcon.Ban, error) {
+			return rcon.QueryBans(serverConf, deadline)
+		})
+}
+
+// missingBans returns shared bans which aren't in current bans of server
+func missingBans(current []rcon.Ban, bans []SharedBan) []SharedBan {
+	var missing []SharedBan
+
+	banned := make(map[string]bool)
+	for _, item := range current {
+		banned[item.Address] = true
+	}
+	for _, ban := range bans {
+		if !banned[ban.Address] {
+			missing = append(missing, ban)
+		}
+	}
+	return missing
+}
+
+// applyBans bans addresses on server unless they are already banned,
+// ban list of server is queried once
+func applyBans(serverConf *rcon.ServerConfig, bans []SharedBan) error {
+	current, err := queryBans(serverConf)
+	if err != nil {
+		return err
+	}
+	for _, ban := range missingBans(current, bans) {
+		deadline := time.Now().Add(time.Millisecond * 1000)
+		err := rcon.AddBan(serverConf, deadline, ban.Address, time.Until(ban.Expires), ban.Reason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liftBan removes all bans of address on server
+func liftBan(serverConf *rcon.ServerConfig, address string) (bool, error) {
+	found := false
+	current, err := queryBans(serverConf)
+	if err != nil {
+		return false, err
+	}
+	// ids are shifted after unban, so remove from the end
+	for i := len(current) - 1; i >= 0; i-- {
+		if current[i].Address != address {
+			continue
+		}
+		found = true
+		deadline := time.Now().Add(time.Millisecond * 1000)
+		err = rcon.RemoveBan(serverConf, deadline, current[i].ID)
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// forEachServer runs fn concurrently for all configured servers and returns errors by server name
+func forEachServer(fn func(serverConf *rcon.ServerConfig) error) map[string]string {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+
+	failures := make(map[string]string)
+	for name, serverConf := range getConfig().Servers {
+		wg.Add(1)
+		go func(name string, serverConf rcon.ServerConfig) {
+			defer wg.Done()
+			err := fn(&serverConf)
+			if err != nil {
+				lock.Lock()
+				failures[name] = err.Error()
+				lock.Unlock()
+			}
+		}(name, serverConf)
+	}
+	wg.Wait()
+	return failures
+}
+
+// syncBans periodically re-applies shared bans, so servers which were
+// restarted and lost their bans get them back
+func syncBans(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			bans, err := banList.List()
+			if err != nil {
+				if err != errBanListDisabled {
+					slog.Error("Can't load shared ban list", "error", err)
+				}
+				continue
+			}
+			if len(bans) == 0 {
+				continue
+			}
+			failures := forEachServer(func(serverConf *rcon.ServerConfig) error {
+				return applyBans(serverConf, bans)
+			})
+			for name, err := range failures {
+				slog.Warn("Can't sync bans to server", "server", name, "error", err)
+			}
+		}
+	}
+}
+
+func decodeBanRequest(r *http.Request) (*BanRequest, error) {
+	var req BanRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, err
+	}
+	if !rcon.ValidBanAddress(req.Address) {
+		return nil, fmt.Errorf("Invalid ban address %q", req.Address)
+	}
+	if req.Duration <= 0 {
+		return nil, errors.New("Ban duration should be positive number of seconds")
+	}
+	if !rcon.ValidCvarValue(req.Reason) {
+		return nil, errors.New("Ban reason contains invalid characters")
+	}
+	return &req, nil
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	writeJSONStatus(w, http.StatusOK, value)
+}
+
+// writeJSONStatus writes value with status, headers are set before status is written
+func writeJSONStatus(w http.ResponseWriter, status int, value interface{}) {
+	json, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(json)
+}
+
+func serverBans(w http.ResponseWriter, r *http.Request) {
+	serverConf, ok := getConfig().Servers[chi.URLParam(r, "server")]
+	if !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	bans, err := queryBans(&serverConf)
+	if err != nil {
+		http.Error(w, "Can't load data from server", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, struct {
+		Bans []rcon.Ban `json:"bans"`
+	}{bans})
+}
+
+func addServerBan(w http.ResponseWriter, r *http.Request) {
+	serverConf, ok := getConfig().Servers[chi.URLParam(r, "server")]
+	if !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	req, err := decodeBanRequest(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	deadline := time.Now().Add(time.Millisecond * 1000)
+	err = rcon.AddBan(&serverConf, deadline, req.Address, time.Duration(req.Duration)*time.Second, req.Reason)
+	if err != nil {
+		http.Error(w, "Can't ban on server", http.StatusInternalServerError)
+		return
+	}
+	serverBans(w, r)
+}
+
+func removeServerBan(w http.ResponseWriter, r *http.Request) {
+	serverConf, ok := getConfig().Servers[chi.URLParam(r, "server")]
+	if !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	address, err := url.PathUnescape(chi.URLParam(r, "address"))
+	if err != nil {
+		http.Error(w, "Invalid ban address", http.StatusBadRequest)
+		return
+	}
+	found, err := liftBan(&serverConf, address)
+	if err != nil {
+		http.Error(w, "Can't unban on server", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Ban not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeBanListError(w http.ResponseWriter, err error) {
+	if err == errBanListDisabled {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	} else {
+		slog.Error("Shared ban list error", "error", err)
+		http.Error(w, "Can't update shared ban list", http.StatusInternalServerError)
+	}
+}
+
+func sharedBans(w http.ResponseWriter, r *http.Request) {
+	bans, err := banList.List()
+	if err != nil {
+		writeBanListError(w, err)
+		return
+	}
+	writeJSON(w, struct {
+		Bans []SharedBan `json:"bans"`
+	}{bans})
+}
+
+// addSharedBan stores ban in shared list and applies it to all servers
+func addSharedBan(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeBanRequest(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	ban := SharedBan{
+		Address: req.Address,
+		Reason:  req.Reason,
+		Expires: time.Now().Add(time.Duration(req.Duration) * time.Second),
+	}
+	err = banList.Add(ban)
+	if err != nil {
+		writeBanListError(w, err)
+		return
+	}
+	failures := forEachServer(func(serverConf *rcon.ServerConfig) error {
+		return applyBans(serverConf, []SharedBan{ban})
+	})
+	writeJSON(w, struct {
+		Ban    SharedBan         `json:"ban"`
+		Errors map[string]string `json:"errors"`
+	}{ban, failures})
+}
+
+func removeSharedBan(w http.ResponseWriter, r *http.Request) {
+	// idfp can contain slashes, so it's escaped in url
+	address, err := url.PathUnescape(chi.URLParam(r, "address"))
+	if err != nil {
+		http.Error(w, "Invalid ban address", http.StatusBadRequest)
+		return
+	}
+	found, err := banList.Remove(address)
+	if err != nil {
+		writeBanListError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "Ban not found", http.StatusNotFound)
+		return
+	}
+	failures := forEachServer(func(serverConf *rcon.ServerConfig) error {
+		_, err := liftBan(serverConf, address)
+		return err
+	})
+	writeJSON(w, struct {
+		Errors map[string]string `json:"errors"`
+	}{failures})
+}
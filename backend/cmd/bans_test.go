@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+func TestMissingBans(t *testing.T) {
+	current := []rcon.Ban{{ID: 0, Address: "1.2.3.4"}, {ID: 1, Address: "5.6.7"}}
+	bans := []SharedBan{{Address: "5.6.7"}, {Address: "idfp="}, {Address: "1.2.3.4"}, {Address: "8.8.8.8"}}
+	missing := missingBans(current, bans)
+	if len(missing) != 2 || missing[0].Address != "idfp=" || missing[1].Address != "8.8.8.8" {
+		t.Error("Incorrect missing bans ", missing)
+	}
+}
+
+func TestBanListPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	list := &BanList{path: func() string { return path }}
+	expires := time.Now().Add(time.Hour).Round(time.Second)
+
+	if bans, err := list.List(); err != nil || len(bans) != 0 {
+		t.Fatal("Missing file should be empty list ", bans, err)
+	}
+	list.Add(SharedBan{Address: "1.2.3.4", Reason: "aimbot", Expires: expires})
+	list.Add(SharedBan{Address: "5.6.7", Expires: expires})
+	// ban for the same address is replaced
+	list.Add(SharedBan{Address: "1.2.3.4", Reason: "wallhack", Expires: expires})
+	list.Add(SharedBan{Address: "idfp=", Expires: time.Now().Add(-time.Minute)})
+	if found, err := list.Remove("5.6.7"); !found || err != nil {
+		t.Error("Ban should be removed ", found, err)
+	}
+	if found, _ := list.Remove("9.9.9.9"); found {
+		t.Error("Unknown ban shouldn't be found")
+	}
+
+	loaded := &BanList{path: func() string { return path }}
+	bans, err := loaded.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 1 || bans[0].Address != "1.2.3.4" || bans[0].Reason != "wallhack" || !bans[0].Expires.Equal(expires) {
+		t.Error("Incorrect loaded bans ", bans)
+	}
+}
+
+func TestBanListErrors(t *testing.T) {
+	disabled := &BanList{path: func() string { return "" }}
+	if _, err := disabled.List(); err != errBanListDisabled {
+		t.Error("Ban list should be disabled ", err)
+	}
+	path := filepath.Join(t.TempDir(), "bans.json")
+	os.WriteFile(path, []byte("{"), 0600)
+	broken := &BanList{path: func() string { return path }}
+	if _, err := broken.List(); err == nil {
+		t.Error("Invalid json should be an error")
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultChatRate    = 1.0
+	defaultChatBurst   = 5
+	maxChatNameLength  = 32
+	maxChatMessageSize = 200
+	chatQueueSize      = 64
+)
+
+type ChatConfig struct {
+	// webhook receives chat messages from game servers
+	Webhook string `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	// token for sending messages to game servers
+	Token  rcon.Secret `json:"token,omitempty" yaml:"token,omitempty"`
+	Prefix string      `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// messages per second in each direction
+	Rate  float64 `json:"rate,omitempty" yaml:"rate,omitempty"`
+	Burst int     `json:"burst,omitempty" yaml:"burst,omitempty"`
+}
+
+type ChatMessage struct {
+	Server  string `json:"server"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	// preformatted message for discord-like webhooks
+	Content string `json:"content"`
+}
+
+// allowedMentions of discord webhook, empty parse list disables @everyone, @here,
+// user and role mentions typed by players
+type allowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+// noMentions is added to webhook payloads with text of players
+var noMentions = allowedMentions{Parse: []string{}}
+
+type OutgoingChatMessage struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// rateLimiter is token bucket, rate and burst are passed on each call
+// so they can be changed by config reload
+type rateLimiter struct {
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func (l *rateLimiter) Allow(rate float64, burst int) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if l.last.IsZero() {
+		l.tokens = float64(burst)
+	} else {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+	}
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+type ChatRelay struct {
+	config   func() *ChatConfig
+	client   *http.Client
+	lock     sync.Mutex
+	players  map[string]map[string]string
+	partial  map[string]string
+	inbound  rateLimiter
+	outbound map[string]*rateLimiter
+	queue    chan ChatMessage
+}
+
+func NewChatRelay(config func() *ChatConfig) *ChatRelay {
+	return &ChatRelay{
+		config:   config,
+		client:   &http.Client{Timeout: time.Second * 5},
+		players:  make(map[string]map[string]string),
+		partial:  make(map[string]string),
+		outbound: make(map[string]*rateLimiter),
+		queue:    make(chan ChatMessage, chatQueueSize),
+	}
+}
+
+func chatLimits(conf *ChatConfig) (float64, int) {
+	rate, burst := conf.Rate, conf.Burst
+	if rate <= 0 {
+		rate = defaultChatRate
+	}
+	if burst <= 0 {
+		burst = defaultChatBurst
+	}
+	return rate, burst
+}
+
+// Forget drops state of server, it's called when server is removed from config
+func (c *ChatRelay) Forget(server string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.players, server)
+	delete(c.partial, server)
+	delete(c.outbound, server)
+}
+
+// HandlePacket processes log packet received from server with log_dest_udp,
+// lines can be splitted between packets
+func (c *ChatRelay) HandlePacket(server string, data []byte) []ChatMessage {
+	var messages []ChatMessage
+
+	data = bytes.TrimPrefix(data, []byte(rcon.RconResponseHeader))
+	c.lock.Lock()
+	text := c.partial[server] + string(data)
+	lines := strings.Split(text, "\n")
+	c.partial[server] = lines[len(lines)-1]
+	c.lock.Unlock()
+	for _, line := range lines[:len(lines)-1] {
+		msg := c.HandleLine(server, line)
+		if msg != nil {
+			messages = append(messages, *msg)
+		}
+	}
+	return messages
+}
+
+// HandleLine parses eventlog line, it tracks player names and returns public chat messages
+func (c *ChatRelay) HandleLine(server, line string) *ChatMessage {
+	line = strings.TrimPrefix(strings.TrimRight(line, "\r"), "^7")
+	if !strings.HasPrefix(line, ":") {
+		return nil
+	}
+	parts := strings.SplitN(line[1:], ":", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	event, args := parts[0], parts[1]
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	players, ok := c.players[server]
+	if !ok {
+		players = make(map[string]string)
+		c.players[server] = players
+	}
+	switch event {
+	case "join":
+		// :join:<player id>:<slot>:<ip>:<nickname>
+		fields := strings.SplitN(args, ":", 4)
+		if len(fields) == 4 {
+			players[fields[0]] = rcon.StripColors(fields[3])
+		}
+	case "name":
+		// :name:<player id>:<nickname>
+		fields := strings.SplitN(args, ":", 2)
+		if len(fields) == 2 {
+			players[fields[0]] = rcon.StripColors(fields[1])
+		}
+	case "part":
+		delete(players, args)
+	case "chat":
+		// :chat:<player id>:<message>, team chat uses other events
+		fields := strings.SplitN(args, ":", 2)
+		if len(fields) != 2 {
+			return nil
+		}
+		name, ok := players[fields[0]]
+		if !ok {
+			name = "player #" + fields[0]
+		}
+		message := rcon.StripColors(fields[1])
+		return &ChatMessage{
+			Server:  server,
+			Name:    name,
+			Message: message,
+			Content: fmt.Sprintf("[%s] %s: %s", server, name, message),
+		}
+	}
+	return nil
+}
+
+// Post sends message to webhook
+func (c *ChatRelay) Post(msg ChatMessage) error {
+	conf := c.config()
+	if conf == nil || conf.Webhook == "" {
+		return nil
+	}
+	rate, burst := chatLimits(conf)
+	if !c.inbound.Allow(rate, burst) {
+		return fmt.Errorf("rate limit exceeded, message from %s dropped", msg.Server)
+	}
+	data, err := json.Marshal(struct {
+		ChatMessage
+		AllowedMentions allowedMentions `json:"allowed_mentions"`
+	}{msg, noMentions})
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Post(conf.Webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Listen receives server logs on udp address, servers should have
+// log_dest_udp pointed to it and sv_eventlog_console enabled
+func (c *ChatRelay) Listen(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		for msg := range c.queue {
+			if err := c.Post(msg); err != nil {
+				slog.Warn("Chat relay error", "error", err)
+			}
+		}
+	}()
+	buf := make([]byte, rcon.XonMSS)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			close(c.queue)
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		server, ok := serverByAddr(from)
+		if !ok {
+			continue
+		}
+		for _, msg := range c.HandlePacket(server, buf[:n]) {
+			select {
+			case c.queue <- msg:
+			default:
+				slog.Warn("Chat relay queue is full, message dropped", "server", server)
+			}
+		}
+	}
+}
+
+// serverByAddr finds configured server by its udp address
+func serverByAddr(addr net.Addr) (string, bool) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return "", false
+	}
+	for name, serverConf := range getConfig().Servers {
+		if serverConf.Port != udpAddr.Port {
+			continue
+		}
+		// hostnames are resolved through dns cache of rcon, so packets don't cause lookups
+		ip, err := serverConf.ResolveIP(time.Now().Add(time.Second))
+		if err == nil && ip.Equal(udpAddr.IP) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// sanitizeChat makes text safe for say command, $ is escaped, so server doesn't expand cvars
+func sanitizeChat(str string, maxLength int) string {
+	str = strings.NewReplacer("\"", "'", ";", ",", "\\", "/", "\r", " ", "\n", " ", "\x00", "", "$", "$$").Replace(str)
+	str = strings.TrimSpace(str)
+	if len(str) > maxLength {
+		// length is limited in bytes, but multi-byte character isn't cut in half
+		cut := maxLength
+		for cut > 0 && !utf8.RuneStart(str[cut]) {
+			cut--
+		}
+		str = str[:cut]
+		// truncation shouldn't leave half of escaped $
+		if dollars := len(str) - len(strings.TrimRight(str, "$")); dollars%2 != 0 {
+			str = str[:len(str)-1]
+		}
+	}
+	return str
+}
+
+func (c *ChatRelay) outboundLimiter(server string) *rateLimiter {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	limiter, ok := c.outbound[server]
+	if !ok {
+		limiter = new(rateLimiter)
+		c.outbound[server] = limiter
+	}
+	return limiter
+}
+
+func sendChat(w http.ResponseWriter, r *http.Request) {
+	var msg OutgoingChatMessage
+
+	conf := getConfig()
+	if conf.Chat == nil {
+		http.Error(w, "Chat relay isn't configured", http.StatusNotImplemented)
+		return
+	}
+	serverName := chi.URLParam(r, "server")
+	serverConf, ok := conf.PublicServer(serverName)
+	if !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	err := json.NewDecoder(r.Body).Decode(&msg)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	name := sanitizeChat(msg.Name, maxChatNameLength)
+	message := sanitizeChat(msg.Message, maxChatMessageSize)
+	if name == "" || message == "" {
+		http.Error(w, "Name and message are required", http.StatusBadRequest)
+		return
+	}
+	rate, burst := chatLimits(conf.Chat)
+	if !chatRelay.outboundLimiter(serverName).Allow(rate, burst) {
+		http.Error(w, "Too many messages", http.StatusTooManyRequests)
+		return
+	}
+	prefix := sanitizeChat(conf.Chat.Prefix, maxChatNameLength)
+	deadline := time.Now().Add(time.Millisecond * 1000)
+	err = rcon.Say(&serverConf, deadline, fmt.Sprintf("%s%s^7: %s", prefix, name, message))
+	if err != nil {
+		http.Error(w, "Can't send message to server", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
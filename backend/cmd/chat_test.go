@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+const chatLog = "\xFF\xFF\xFF\xFFn:join:5:1:127.0.0.1:^1Red^7Player\n" +
+	":chat:5:hello ^2world\n:chat_team:5:team only\n:chat:7:who am i\n:name:5:Renamed\n:ch"
+
+func TestChatRelayHandlePacket(t *testing.T) {
+	relay := NewChatRelay(func() *ChatConfig { return nil })
+	messages := relay.HandlePacket("pub", []byte(chatLog))
+	if len(messages) != 2 {
+		t.Fatal("Incorrect number of chat messages ", messages)
+	}
+	if messages[0].Name != "RedPlayer" || messages[0].Message != "hello world" || messages[0].Server != "pub" {
+		t.Error("Incorrectly parsed first message ", messages[0])
+	}
+	if messages[1].Name != "player #7" {
+		t.Error("Unknown player should be named by id ", messages[1])
+	}
+	// line is finished in next packet
+	messages = relay.HandlePacket("pub", []byte("at:5:renamed\n"))
+	if len(messages) != 1 || messages[0].Name != "Renamed" {
+		t.Error("Incorrectly parsed splitted message ", messages)
+	}
+	relay.Forget("pub")
+	messages = relay.HandlePacket("pub", []byte(":chat:5:forgotten\n"))
+	if len(messages) != 1 || messages[0].Name != "player #5" {
+		t.Error("Server state wasn't forgotten ", messages)
+	}
+}
+
+func TestChatRelayPost(t *testing.T) {
+	var received []ChatMessage
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ChatMessage
+			AllowedMentions *allowedMentions `json:"allowed_mentions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error("Invalid webhook payload ", err)
+		}
+		if msg.AllowedMentions == nil || msg.AllowedMentions.Parse == nil || len(msg.AllowedMentions.Parse) != 0 {
+			t.Error("Mentions should be disabled ", msg.AllowedMentions)
+		}
+		received = append(received, msg.ChatMessage)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	conf := &ChatConfig{Webhook: webhook.URL, Rate: 0.001, Burst: 2}
+	relay := NewChatRelay(func() *ChatConfig { return conf })
+	msg := ChatMessage{Server: "pub", Name: "Player", Message: "@everyone hi", Content: "[pub] Player: @everyone hi"}
+	for i := 0; i < 2; i++ {
+		if err := relay.Post(msg); err != nil {
+			t.Error("Error posting message ", err)
+		}
+	}
+	if err := relay.Post(msg); err == nil {
+		t.Error("Message should be rate limited")
+	}
+	if len(received) != 2 || received[0] != msg {
+		t.Error("Incorrect messages received by webhook ", received)
+	}
+}
+
+func TestSanitizeChat(t *testing.T) {
+	if out := sanitizeChat("say \"hi\"; quit\n", 200); out != "say 'hi', quit" {
+		t.Error("Incorrectly sanitized message ", out)
+	}
+	if out := sanitizeChat("long name", 4); out != "long" {
+		t.Error("Message wasn't truncated ", out)
+	}
+	if out := sanitizeChat("$rcon_password", 200); out != "$$rcon_password" {
+		t.Error("Cvar reference wasn't escaped ", out)
+	}
+	if out := sanitizeChat("ab$c", 3); out != "ab" {
+		t.Error("Escaped $ shouldn't be split ", out)
+	}
+	if out := sanitizeChat("añb", 2); out != "a" || !utf8.ValidString(out) {
+		t.Errorf("Multi-byte character shouldn't be split %q", out)
+	}
+	if out := sanitizeChat("日本", 4); out != "日" {
+		t.Errorf("Multi-byte character shouldn't be split %q", out)
+	}
+}
+
+func TestServerByAddr(t *testing.T) {
+	config.Store(&Config{Servers: map[string]rcon.ServerConfig{
+		"ctf": {Server: "127.0.0.1", Port: 26000},
+		"dm":  {Server: "127.0.0.1", Port: 26001},
+	}})
+	if name, ok := serverByAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 26001}); !ok || name != "dm" {
+		t.Error("Incorrect server ", name, ok)
+	}
+	if _, ok := serverByAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 26000}); ok {
+		t.Error("Packet from other address shouldn't match")
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	maxRconPasswordLength = 64
+	minAdminTokenLength   = 16
+)
+
+var envVarRe *regexp.Regexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var reloadLock sync.Mutex
+var serverCacheResets []func(server string)
+
+type ConfigDiff struct {
+	ServersAdded   []string
+	ServersRemoved []string
+	ServersChanged []string
+	GameDBAdded    []string
+	GameDBRemoved  []string
+	GameDIRAdded   []string
+	GameDIRRemoved []string
+}
+
+// interpolateEnv replaces ${VAR} references in string values of parsed config with values
+// from environment, so values can't change structure of document and comments aren't interpolated,
+// reference to undefined variable is an error
+func interpolateEnv(value interface{}) (interface{}, error) {
+	missing := make(map[string]bool)
+	result := interpolateValue(value, missing)
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined environment variables: %s", strings.Join(names, ", "))
+	}
+	return result, nil
+}
+
+func interpolateValue(value interface{}, missing map[string]bool) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		for key, item := range value {
+			value[key] = interpolateValue(item, missing)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = interpolateValue(item, missing)
+		}
+	case string:
+		return interpolateString(value, missing)
+	}
+	return value
+}
+
+func interpolateString(value string, missing map[string]bool) interface{} {
+	result := envVarRe.ReplaceAllStringFunc(value, func(match string) string {
+		name := envVarRe.FindStringSubmatch(match)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			missing[name] = true
+			return match
+		}
+		return env
+	})
+	if result == value || envVarRe.FindString(value) != value {
+		return result
+	}
+	// value is single reference like port: ${PORT}, numbers and booleans keep their type
+	// when they are written the same way after decoding
+	var typed interface{}
+	if yaml.Unmarshal([]byte(result), &typed) == nil {
+		switch typed.(type) {
+		case int, float64, bool:
+			if fmt.Sprint(typed) == result {
+				return typed
+			}
+		}
+	}
+	return result
+}
+
+func readRconPassword(serverConf *rcon.ServerConfig) (rcon.Secret, error) {
+	switch {
+	case serverConf.RconPasswordFile != "":
+		data, err := ioutil.ReadFile(serverConf.RconPasswordFile)
+		if err != nil {
+			return "", err
+		}
+		return rcon.Secret(strings.TrimRight(string(data), "\r\n")), nil
+	case serverConf.RconPasswordEnv != "":
+		value, ok := os.LookupEnv(serverConf.RconPasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", serverConf.RconPasswordEnv)
+		}
+		return rcon.Secret(value), nil
+	default:
+		return serverConf.RconPassword, nil
+	}
+}
+
+// checkRconPassword validates password length, server splits its passwords by spaces,
+// so password with spaces never matches
+func checkRconPassword(password rcon.Secret) error {
+	if len(password) == 0 || len(password) > maxRconPasswordLength {
+		return fmt.Errorf("should be from 1 to %d characters long", maxRconPasswordLength)
+	}
+	if strings.ContainsAny(string(password), " \t") {
+		return errors.New("shouldn't contain spaces, list passwords in rcon_passwords")
+	}
+	return nil
+}
+
+// resolveSecrets loads rcon passwords from files and environment,
+// errors never include password itself
+func resolveSecrets(conf *Config) error {
+	for name, serverConf := range conf.Servers {
+		password, err := readRconPassword(&serverConf)
+		if err != nil {
+			return fmt.Errorf("server %s: can't read rcon password: %w", name, err)
+		}
+		serverConf.RconPassword = password
+		// schema sees only redacted passwords, so duplicates are checked here
+		seen := make(map[rcon.Secret]string)
+		for _, credential := range serverConf.AllCredentials() {
+			if err := checkRconPassword(credential.Password); err != nil {
+				return fmt.Errorf("server %s: %s %w", name, credential.Name, err)
+			}
+			if previous, ok := seen[credential.Password]; ok {
+				return fmt.Errorf("server %s: %s is same as %s", name, credential.Name, previous)
+			}
+			seen[credential.Password] = credential.Name
+		}
+		if len(serverConf.RconPasswords) > 0 && !serverConf.RotationActive(time.Now()) {
+			slog.Warn("Rotation window is over, rcon_passwords aren't used", "server", name)
+		}
+		conf.Servers[name] = serverConf
+	}
+	if conf.AdminToken != "" && len(conf.AdminToken) < minAdminTokenLength {
+		return fmt.Errorf("admin token should be at least %d characters long", minAdminTokenLength)
+	}
+	if conf.Chat != nil && conf.Chat.Token != "" && len(conf.Chat.Token) < minAdminTokenLength {
+		return fmt.Errorf("chat token should be at least %d characters long", minAdminTokenLength)
+	}
+	if conf.Notifications != nil && conf.Notifications.VapidKey != "" {
+		if _, err := vapidPrivateKey(conf.Notifications); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffLists(oldList, newList []string) (added, removed []string) {
+	oldSet := make(map[string]bool)
+	newSet := make(map[string]bool)
+	for _, item := range oldList {
+		oldSet[item] = true
+	}
+	for _, item := range newList {
+		newSet[item] = true
+		if !oldSet[item] {
+			added = append(added, item)
+		}
+	}
+	for _, item := range oldList {
+		if !newSet[item] {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
+
+func diffConfig(oldConf, newConf *Config) *ConfigDiff {
+	var diff ConfigDiff
+
+	for name, serverConf := range newConf.Servers {
+		oldServerConf, ok := oldConf.Servers[name]
+		if !ok {
+			diff.ServersAdded = append(diff.ServersAdded, name)
+		} else if !reflect.DeepEqual(oldServerConf, serverConf) {
+			diff.ServersChanged = append(diff.ServersChanged, name)
+		}
+	}
+	for name := range oldConf.Servers {
+		if _, ok := newConf.Servers[name]; !ok {
+			diff.ServersRemoved = append(diff.ServersRemoved, name)
+		}
+	}
+	sort.Strings(diff.ServersAdded)
+	sort.Strings(diff.ServersRemoved)
+	sort.Strings(diff.ServersChanged)
+	diff.GameDBAdded, diff.GameDBRemoved = diffLists(oldConf.GameDB, newConf.GameDB)
+	diff.GameDIRAdded, diff.GameDIRRemoved = diffLists(oldConf.GameDIR, newConf.GameDIR)
+	return &diff
+}
+
+func (d *ConfigDiff) String() string {
+	var parts []string
+
+	describe := func(what, action string, items []string) {
+		if len(items) > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s: %s", what, action, strings.Join(items, ", ")))
+		}
+	}
+	describe("servers", "added", d.ServersAdded)
+	describe("servers", "removed", d.ServersRemoved)
+	describe("servers", "changed", d.ServersChanged)
+	describe("gamedb", "added", d.GameDBAdded)
+	describe("gamedb", "removed", d.GameDBRemoved)
+	describe("gamedir", "added", d.GameDIRAdded)
+	describe("gamedir", "removed", d.GameDIRRemoved)
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// registerServerCache adds callback that drops cached state of server
+// when it's removed from config or its connection settings are changed
+func registerServerCache(reset func(server string)) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	serverCacheResets = append(serverCacheResets, reset)
+}
+
+func reloadConfig(filename string) bool {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	conf, ok := loadConfig(filename)
+	if !ok {
+		slog.Error("Config wasn't updated because of errors")
+		return false
+	}
+	diff := diffConfig(getConfig(), conf)
+	config.Store(conf)
+	for _, reset := range serverCacheResets {
+		for _, name := range diff.ServersRemoved {
+			reset(name)
+		}
+		for _, name := range diff.ServersChanged {
+			reset(name)
+		}
+	}
+	slog.Info("Successfully updated config", "diff", diff.String())
+	return true
+}
+
+// watchConfig reloads config when content of the file is changed
+func watchConfig(ctx context.Context, filename string, interval time.Duration) {
+	var lastSum [md5.Size]byte
+
+	data, err := ioutil.ReadFile(filename)
+	if err == nil {
+		lastSum = md5.Sum(data)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				slog.Error("Error reading config", "error", err)
+				continue
+			}
+			sum := md5.Sum(data)
+			if sum == lastSum {
+				continue
+			}
+			lastSum = sum
+			slog.Info("Config file was changed, reloading")
+			reloadConfig(filename)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("TEST_PORT", "26001")
+	t.Setenv("TEST_PASSWORD", "a: b # c\nservers: {}")
+	t.Setenv("TEST_MODE", "007")
+	filename := writeTestConfig(t, `
+# ${TEST_UNDEFINED} in comments isn't interpolated
+servers:
+  ctf:
+    server: 127.0.0.1
+    port: ${TEST_PORT}
+    rcon_password: ${TEST_PASSWORD}
+    labels:
+      mode: ${TEST_MODE}
+gamedb:
+  - server.db
+`)
+	conf, ok := parseConfig(filename)
+	if !ok {
+		t.Fatal("Config should be valid")
+	}
+	serverConf := conf.Servers["ctf"]
+	if serverConf.Port != 26001 {
+		t.Error("Incorrect port ", serverConf.Port)
+	}
+	if serverConf.RconPassword != "a: b # c\nservers: {}" {
+		t.Errorf("Incorrect password %q", string(serverConf.RconPassword))
+	}
+	if serverConf.Labels["mode"] != "007" {
+		t.Error("Incorrect label ", serverConf.Labels)
+	}
+}
+
+func TestInterpolateEnvUndefined(t *testing.T) {
+	filename := writeTestConfig(t, `
+servers:
+  ctf:
+    server: 127.0.0.1
+    rcon_password: ${TEST_UNDEFINED}
+gamedb:
+  - server.db
+`)
+	if _, ok := parseConfig(filename); ok {
+		t.Error("Undefined variable should be rejected")
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	oldConf := &Config{
+		Servers: map[string]rcon.ServerConfig{
+			"ctf":  {Server: "127.0.0.1", Port: 26000},
+			"dm":   {Server: "127.0.0.1", Port: 26001},
+			"duel": {Server: "127.0.0.1", Port: 26002},
+		},
+		GameDB: []string{"ctf.db", "dm.db"},
+	}
+	newConf := &Config{
+		Servers: map[string]rcon.ServerConfig{
+			"ctf":  {Server: "127.0.0.1", Port: 26000},
+			"dm":   {Server: "127.0.0.1", Port: 26005},
+			"race": {Server: "127.0.0.1", Port: 26003},
+		},
+		GameDB:  []string{"ctf.db", "race.db"},
+		GameDIR: []string{"data"},
+	}
+	diff := diffConfig(oldConf, newConf)
+	expected := "servers added: race; servers removed: duel; servers changed: dm; " +
+		"gamedb added: race.db; gamedb removed: dm.db; gamedir added: data"
+	if diff.String() != expected {
+		t.Error("Incorrect diff ", diff)
+	}
+	if diff := diffConfig(oldConf, oldConf); diff.String() != "no changes" {
+		t.Error("Incorrect diff ", diff)
+	}
+}
+
+const reloadTestConfig = `
+servers:
+  ctf:
+    server: 127.0.0.1
+    port: %d
+    rcon_password: secret
+gamedb:
+  - server.db
+`
+
+func TestReloadConfig(t *testing.T) {
+	var resets []string
+
+	defer func(resetsBefore []func(string)) {
+		serverCacheResets = resetsBefore
+	}(serverCacheResets)
+	serverCacheResets = nil
+	registerServerCache(func(server string) {
+		resets = append(resets, server)
+	})
+
+	filename := writeTestConfig(t, fmt.Sprintf(reloadTestConfig, 26000))
+	conf, ok := loadConfig(filename)
+	if !ok {
+		t.Fatal("Config should be valid")
+	}
+	config.Store(conf)
+	os.WriteFile(filename, []byte(fmt.Sprintf(reloadTestConfig, 26001)), 0600)
+	if !reloadConfig(filename) {
+		t.Fatal("Config should be reloaded")
+	}
+	if getConfig().Servers["ctf"].Port != 26001 || !slices.Equal(resets, []string{"ctf"}) {
+		t.Error("Changed server should be reset ", getConfig().Servers["ctf"], resets)
+	}
+
+	// invalid config is ignored
+	os.WriteFile(filename, []byte("servers: [\n"), 0600)
+	if reloadConfig(filename) {
+		t.Error("Invalid config shouldn't be loaded")
+	}
+	if getConfig().Servers["ctf"].Port != 26001 {
+		t.Error("Previous config should be kept")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	filename := writeTestConfig(t, fmt.Sprintf(reloadTestConfig, 26000))
+	conf, _ := loadConfig(filename)
+	config.Store(conf)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchConfig(ctx, filename, time.Millisecond*10)
+
+	// watcher reads initial content before first tick
+	time.Sleep(time.Millisecond * 50)
+	os.WriteFile(filename, []byte(fmt.Sprintf(reloadTestConfig, 26002)), 0600)
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond * 10) {
+		if getConfig().Servers["ctf"].Port == 26002 {
+			return
+		}
+	}
+	t.Error("Changed config wasn't reloaded")
+}
+
+func TestLoadConfigSecrets(t *testing.T) {
+	filename := writeTestConfig(t, `
+servers:
+  ctf:
+    server: 127.0.0.1
+    port: 26000
+    rcon_password_file: /nonexistent/password
+gamedb:
+  - server.db
+`)
+	if _, ok := parseConfig(filename); !ok {
+		t.Fatal("Config should be valid without secrets")
+	}
+	if _, ok := loadConfig(filename); ok {
+		t.Error("Missing password file should be rejected")
+	}
+}
+
+const rotationTestConfig = `
+servers:
+  ctf:
+    server: 127.0.0.1
+    port: 26000
+    rcon_password: new
+    rcon_passwords: [%s]
+    rcon_restricted_password: viewer
+%s
+gamedb:
+  - server.db
+`
+
+func TestConfigRconPasswords(t *testing.T) {
+	window := "    rcon_rotation_until: " + time.Now().Add(time.Hour).Format(time.RFC3339)
+	filename := writeTestConfig(t, fmt.Sprintf(rotationTestConfig, "old, older", window))
+	conf, ok := loadConfig(filename)
+	if !ok {
+		t.Fatal("Config with rotation passwords should be valid")
+	}
+	serverConf := conf.Servers["ctf"]
+	if len(serverConf.Credentials(false)) != 3 {
+		t.Error("Rotation passwords should be used ", serverConf.Credentials(false))
+	}
+
+	filename = writeTestConfig(t, fmt.Sprintf(rotationTestConfig, "old, old", window))
+	if _, ok := loadConfig(filename); ok {
+		t.Error("Duplicate passwords should be rejected")
+	}
+	filename = writeTestConfig(t, fmt.Sprintf(rotationTestConfig, "old, viewer", window))
+	if _, ok := loadConfig(filename); ok {
+		t.Error("Restricted password shouldn't be same as full one")
+	}
+	filename = writeTestConfig(t, fmt.Sprintf(rotationTestConfig, "old, older", ""))
+	if _, ok := parseConfig(filename); ok {
+		t.Error("Rotation passwords without rotation window should be rejected")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+)
+
+// cvars that are never exposed, even if allowlist matches them
+var deniedCvars = []string{"rcon_*", "*password*"}
+
+func matchCvar(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func cvarAllowed(conf *Config, name string) bool {
+	return matchCvar(conf.Cvars, name) && !matchCvar(deniedCvars, name)
+}
+
+func cvars(w http.ResponseWriter, r *http.Request) {
+	conf := getConfig()
+	serverName := chi.URLParam(r, "server")
+	serverConf, ok := conf.PublicServer(serverName)
+	if !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	names := r.URL.Query()["name"]
+	if len(names) == 0 {
+		http.Error(w, "At least one cvar name is required", http.StatusBadRequest)
+		return
+	}
+	for _, name := range names {
+		if !rcon.ValidCvarName(name) {
+			http.Error(w, fmt.Sprintf("Invalid cvar name %q", name), http.StatusBadRequest)
+			return
+		}
+		if !cvarAllowed(conf, name) {
+			http.Error(w, fmt.Sprintf("Cvar %s is not allowed", name), http.StatusForbidden)
+			return
+		}
+	}
+	result, err := rcon.QueryWithRetries(time.Millisecond*1000, 3,
+		func(deadline time.Time) (map[string]*rcon.Cvar, error) {
+			return rcon.QueryCvars(&serverConf, deadline, names...)
+		})
+	if err != nil {
+		http.Error(w, "Can't load data from server", http.StatusInternalServerError)
+		return
+	}
+	json, err := json.Marshal(struct {
+		Cvars map[string]*rcon.Cvar `json:"cvars"`
+	}{result})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(json)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frontendFS is built frontend from -static directory or embedded dist, frontend isn't served without it
+var frontendFS fs.FS
+
+// frontendRewrites and frontendRedirects repeat firebase.json, so self hosted site works the same way
+var frontendRewrites = map[string]string{
+	"/":         "index.html",
+	"/records/": "index.html",
+	"/servers/": "index.html",
+}
+
+var frontendRedirects = map[string]struct {
+	location string
+	status   int
+}{
+	"/chat":    {"http://webchat.quakenet.org/?channels=#theregulars", http.StatusMovedPermanently},
+	"/discord": {"https://discord.gg/6jxvuSe", http.StatusMovedPermanently},
+	"/configs": {"https://github.com/bacher09/regulars-configs", http.StatusFound},
+}
+
+// precompressed assets are looked up in order of preference
+var frontendEncodings = []struct {
+	name      string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// frontendCacheControl returns Cache-Control from headers of firebase.json
+func frontendCacheControl(name string) string {
+	ext := path.Ext(name)
+	switch {
+	case name == "sw.js":
+		return "max-age=120"
+	case ext == ".eot" || ext == ".otf" || ext == ".ttf" || ext == ".ttc" || ext == ".woff" || ext == ".woff2":
+		return "max-age=5184000"
+	case ext == ".css" || ext == ".js":
+		return "max-age=7776000"
+	case path.Dir(name) == "images" && (ext == ".png" || ext == ".jpg" || ext == ".webp"):
+		return "max-age=604800"
+	}
+	// default of firebase hosting
+	return "max-age=3600"
+}
+
+// acceptsEncoding checks Accept-Encoding, encodings with q=0 are refused
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if key, value, ok := strings.Cut(param, "="); ok && strings.TrimSpace(key) == "q" {
+				quality, _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			}
+		}
+		return quality > 0
+	}
+	return false
+}
+
+type frontend struct {
+	files fs.FS
+}
+
+func newFrontend(files fs.FS) *frontend {
+	return &frontend{files: files}
+}
+
+// readFile reads regular file, directories are reported as missing
+func (f *frontend) readFile(name string) ([]byte, error) {
+	info, err := fs.Stat(f.files, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+	return fs.ReadFile(f.files, name)
+}
+
+func (f *frontend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if redirect, ok := frontendRedirects[r.URL.Path]; ok {
+		http.Redirect(w, r, redirect.location, redirect.status)
+		return
+	}
+	name, ok := frontendRewrites[r.URL.Path]
+	if !ok {
+		name = strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+	}
+	if !fs.ValidPath(name) {
+		f.notFound(w, r)
+		return
+	}
+	data, err := f.readFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		f.notFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't read file", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Header().Set("Cache-Control", frontendCacheControl(name))
+	for _, encoding := range frontendEncodings {
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), encoding.name) {
+			continue
+		}
+		if compressed, err := f.readFile(name + encoding.extension); err == nil {
+			w.Header().Set("Content-Encoding", encoding.name)
+			data = compressed
+			break
+		}
+	}
+	w.Header().Set("Etag", `"`+generateEtag(data)+`"`)
+	// content type is detected from name of uncompressed file
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// notFound serves 404.html like firebase does
+func (f *frontend) notFound(w http.ResponseWriter, r *http.Request) {
+	data, err := f.readFile("404.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", cacheNone)
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(data)
+}
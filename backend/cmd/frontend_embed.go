@@ -0,0 +1,21 @@
+//go:build embedfrontend
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist is copied from frontend build by make backend-site
+//
+//go:embed all:dist
+var embeddedDist embed.FS
+
+func embeddedFrontend() fs.FS {
+	files, err := fs.Sub(embeddedDist, "dist")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
@@ -0,0 +1,10 @@
+//go:build !embedfrontend
+
+package main
+
+import "io/fs"
+
+// embeddedFrontend is nil without embedfrontend build tag, frontend can be served with -static
+func embeddedFrontend() fs.FS {
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func serveFrontend(target string, header http.Header) *httptest.ResponseRecorder {
+	frontendFS = fstest.MapFS{
+		"index.html":                {Data: []byte("<html>index</html>")},
+		"404.html":                  {Data: []byte("<html>not found</html>")},
+		"sw.js":                     {Data: []byte("self.addEventListener()")},
+		"assets/main.js":            {Data: []byte("console.log(1)")},
+		"assets/main.js.br":         {Data: []byte("brotli")},
+		"assets/main.js.gz":         {Data: []byte("gzip")},
+		"assets/font.woff2":         {Data: []byte("font")},
+		"images/logo.png":           {Data: []byte("png")},
+		"images/maps/bloodrage.jpg": {Data: []byte("jpg")},
+	}
+	defer func() {
+		frontendFS = nil
+	}()
+	return serveTest("GET", target, header)
+}
+
+func TestFrontendRewrites(t *testing.T) {
+	for _, target := range []string{"/", "/records/", "/servers/?server=ctf"} {
+		w := serveFrontend(target, nil)
+		if w.Code != http.StatusOK || w.Body.String() != "<html>index</html>" {
+			t.Errorf("%s: incorrect response %d %s", target, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("%s: incorrect content type %s", target, w.Header().Get("Content-Type"))
+		}
+	}
+	// API aliases without trailing slash aren't replaced by frontend
+	if w := serveFrontend("/servers", nil); w.Header().Get("Content-Type") != "application/json" {
+		t.Error("API route is shadowed by frontend ", w.Body.String())
+	}
+	w := serveFrontend("/missing.html", nil)
+	if w.Code != http.StatusNotFound || w.Body.String() != "<html>not found</html>" {
+		t.Error("Incorrect not found page ", w.Code, w.Body.String())
+	}
+	if w := serveFrontend("/assets/", nil); w.Code != http.StatusNotFound {
+		t.Error("Directories shouldn't be listed ", w.Code)
+	}
+	if w := serveFrontend("/api/v1/missing", nil); w.Header().Get("Content-Type") != "application/json" {
+		t.Error("API errors shouldn't use frontend ", w.Body.String())
+	}
+}
+
+func TestFrontendRedirects(t *testing.T) {
+	w := serveFrontend("/discord", nil)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://discord.gg/6jxvuSe" {
+		t.Error("Incorrect redirect ", w.Code, w.Header())
+	}
+	if w := serveFrontend("/configs", nil); w.Code != http.StatusFound {
+		t.Error("Incorrect redirect status ", w.Code)
+	}
+}
+
+func TestFrontendCacheControl(t *testing.T) {
+	expected := map[string]string{
+		"/sw.js":                     "max-age=120",
+		"/assets/main.js":            "max-age=7776000",
+		"/assets/font.woff2":         "max-age=5184000",
+		"/images/logo.png":           "max-age=604800",
+		"/images/maps/bloodrage.jpg": "max-age=3600",
+		"/":                          "max-age=3600",
+	}
+	for target, cache := range expected {
+		if w := serveFrontend(target, nil); w.Header().Get("Cache-Control") != cache {
+			t.Errorf("%s: expected %s, got %s", target, cache, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestFrontendPrecompressed(t *testing.T) {
+	cases := []struct {
+		accept   string
+		encoding string
+		body     string
+	}{
+		{"", "", "console.log(1)"},
+		{"gzip, deflate, br", "br", "brotli"},
+		{"gzip, br;q=0", "gzip", "gzip"},
+		{"identity", "", "console.log(1)"},
+	}
+	for _, c := range cases {
+		w := serveFrontend("/assets/main.js", http.Header{"Accept-Encoding": {c.accept}})
+		if w.Header().Get("Content-Encoding") != c.encoding || w.Body.String() != c.body {
+			t.Errorf("%q: incorrect response %s %s", c.accept, w.Header().Get("Content-Encoding"), w.Body.String())
+		}
+		if w.Header().Get("Content-Type") != "text/javascript; charset=utf-8" || w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%q: incorrect headers %v", c.accept, w.Header())
+		}
+	}
+
+	etag := serveFrontend("/assets/main.js", nil).Header().Get("Etag")
+	if w := serveFrontend("/assets/main.js", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Error("Etag isn't checked ", w.Code)
+	}
+}
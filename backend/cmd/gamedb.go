@@ -1,26 +1,23 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
-	"log"
-	"net/url"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/gamedb"
 )
 
 const (
-	pInitialState     = 0
-	pSeenNewLine      = 1
-	pKeyInput         = 2
-	pValueInput       = 3
 	captimeNetnameSuf = "/captimerecord/netname"
 	captimeSuf        = "/captimerecord/time"
 )
 
-type DBCallback = func(key, value string, state interface{})
-
 type RecordItem struct {
 	Name  string  `json:"name"`
 	Value float64 `json:"val"`
@@ -28,26 +25,37 @@ type RecordItem struct {
 
 type Records = map[string]*RecordItem
 
-func updateRecords(key, value string, istate interface{}) {
-	state, ok := istate.(Records)
-	if !ok {
-		return
-	}
-	if strings.HasSuffix(key, captimeNetnameSuf) {
-		mapname := strings.TrimSuffix(key, captimeNetnameSuf)
+// recordKeys are gamedb key suffixes of best record in gametype
+type recordKeys struct {
+	time    string
+	netname string
+	// multiplier for converting stored time to seconds
+	scale float64
+}
+
+var gametypeRecordKeys = map[string]recordKeys{
+	"ctf":  {captimeSuf, captimeNetnameSuf, 1},
+	"cts":  {"/cts100record/time1", "/cts100record/netname1", 0.01},
+	"race": {"/race100record/time1", "/race100record/netname1", 0.01},
+}
+
+func updateRecords(state Records, keys recordKeys, key, value string) {
+	if strings.HasSuffix(key, keys.netname) {
+		mapname := strings.TrimSuffix(key, keys.netname)
 		item, ok := state[mapname]
 		if ok {
 			item.Name = value
 		} else {
 			state[mapname] = &RecordItem{value, 0.0}
 		}
-	} else if strings.HasSuffix(key, captimeSuf) {
-		mapname := strings.TrimSuffix(key, captimeSuf)
+	} else if strings.HasSuffix(key, keys.time) {
+		mapname := strings.TrimSuffix(key, keys.time)
 		record, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			log.Printf("Can't parse float in gamedb: %v", err)
+			slog.Warn("Can't parse float in gamedb", "key", key, "error", err)
 			return
 		}
+		record *= keys.scale
 		item, ok := state[mapname]
 		if ok {
 			item.Value = record
@@ -69,74 +77,65 @@ func mergeRecords(main, temp Records) {
 	}
 }
 
-func ReadXonoticDB(filePath string, callback DBCallback, state interface{}) error {
-	var keyBuf, valueBuf bytes.Buffer
-
+// readRecords reads records from gamedb, only record keys are passed to filter
+func readRecords(filePath string, keys recordKeys, filter func(key, value string) bool) (Records, error) {
+	records := make(Records)
 	file, err := os.Open(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
-	reader := bufio.NewReader(file)
-	parserState := pInitialState
-	consumeItem := func() {
-
-		if parserState != pValueInput {
-			// we are called from invalid state, ignore
-			return
-		}
-
-		valueStr, err := url.QueryUnescape(valueBuf.String())
-		if err != nil {
-			log.Printf("Uneable to parse gamedb value: %v", err)
-			return
-		}
-		callback(keyBuf.String(), valueStr, state)
-		keyBuf.Reset()
-		valueBuf.Reset()
-	}
+	tokenizer := gamedb.NewTokenizer(file)
+	skipped := 0
+	var firstErr *gamedb.SyntaxError
 	for {
-		b, err := reader.ReadByte()
-		if err != nil {
-			// reached end of file
+		key, value, err := tokenizer.Next()
+		if err == io.EOF {
 			break
 		}
-		switch b {
-		case '\n':
-			consumeItem()
-			parserState = pSeenNewLine
-		case '\\':
-			if parserState == pSeenNewLine {
-				parserState = pKeyInput
-			} else if parserState == pKeyInput {
-				parserState = pValueInput
-			} else if parserState == pValueInput {
-				consumeItem()
-				parserState = pKeyInput
-			}
-		default:
-			if parserState == pKeyInput {
-				keyBuf.WriteByte(b)
-			} else if parserState == pValueInput {
-				valueBuf.WriteByte(b)
+		// malformed entry doesn't hide other records, server writes database again on map change
+		if syntaxErr, ok := err.(*gamedb.SyntaxError); ok {
+			if firstErr == nil {
+				firstErr = syntaxErr
 			}
+			skipped++
+			tokenizer.SkipLine()
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+		// avoid string allocations for keys which aren't records
+		if !bytes.HasSuffix(key, []byte(keys.time)) && !bytes.HasSuffix(key, []byte(keys.netname)) {
+			continue
+		}
+		keyStr, valueStr := string(key), string(value)
+		if filter(keyStr, valueStr) {
+			updateRecords(records, keys, keyStr, valueStr)
 		}
 	}
-	return nil
+	if skipped > 0 {
+		slog.Warn("Malformed gamedb lines skipped", "file", filePath, "count", skipped,
+			"line", firstErr.Line, "offset", firstErr.Offset, "error", firstErr.Msg)
+	}
+	return records, nil
 }
 
 func ReadCaptimeRecordsWithFilter(fileList []string, filter func(key, value string) bool) (Records, error) {
+	return ReadRecordsWithFilter(fileList, "ctf", filter)
+}
+
+// ReadRecordsWithFilter reads best records of ctf, cts or race from gamedbs
+func ReadRecordsWithFilter(fileList []string, gametype string, filter func(key, value string) bool) (Records, error) {
 	records := make(Records)
 
-	callback := func(key, value string, istate interface{}) {
-		if filter(key, value) {
-			updateRecords(key, value, istate)
-		}
+	keys, ok := gametypeRecordKeys[gametype]
+	if !ok {
+		return nil, fmt.Errorf("Unknown gametype %s", gametype)
 	}
-
 	for _, filePath := range fileList {
-		tempRecords := make(Records)
-		err := ReadXonoticDB(filePath, callback, tempRecords)
+		start := time.Now()
+		tempRecords, err := readRecords(filePath, keys, filter)
+		gamedbReadDuration.ObserveDuration(start, gametype)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheRegulars/website/backend/pkg/gamedb"
+)
+
+func writeTestGameDB(t testing.TB, maps int) string {
+	db := gamedb.New()
+	for i := 0; i < maps; i++ {
+		mapname := fmt.Sprintf("map%d", i)
+		db.Put(mapname+captimeSuf, fmt.Sprintf("%d.25", 10+i))
+		db.Put(mapname+captimeNetnameSuf, fmt.Sprintf("^1Player %d^7", i))
+		db.Put(mapname+"/captimerecord/crypto_idfp", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEF=")
+		for pos := 1; pos <= 10; pos++ {
+			db.Put(fmt.Sprintf("%s/cts100record/time%d", mapname, pos), fmt.Sprint(1000*pos))
+			db.Put(fmt.Sprintf("%s/cts100record/netname%d", mapname, pos), "Some Player")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "server.db")
+	if err := db.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadCaptimeRecords(t *testing.T) {
+	path := writeTestGameDB(t, 3)
+	other := filepath.Join(filepath.Dir(path), "other.db")
+	data := "8192\n\\map1/captimerecord/time\\5\\map1/captimerecord/netname\\Fast%20One\n" +
+		"\\map2/captimerecord/netname\\Bad%zzEscape\\map2/captimerecord/time\\100\n"
+	if err := os.WriteFile(other, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadCaptimeRecordsWithFilter([]string{path, other}, func(key, value string) bool {
+		return !strings.HasPrefix(key, "map0/")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatal("Incorrect number of records ", records)
+	}
+	if records["map1"].Name != "Fast One" || records["map1"].Value != 5 {
+		t.Error("Better record wasn't merged ", records["map1"])
+	}
+	if records["map2"].Name != "^1Player 2^7" || records["map2"].Value != 12.25 {
+		t.Error("Incorrect record ", records["map2"])
+	}
+}
+
+func TestReadCaptimeRecordsCorruptLine(t *testing.T) {
+	var logs bytes.Buffer
+
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	path := filepath.Join(t.TempDir(), "server.db")
+	data := "8192\n\\map1/captimerecord/time\\5\\map1/captimerecord/netname\\First\n" +
+		"garbage written by crash\n" +
+		"\\map2/captimerecord/time\\7\\map2/captimerecord/netname\\Second\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadCaptimeRecordsWithFilter([]string{path}, func(key, value string) bool { return true })
+	if err != nil {
+		t.Fatal("Corrupt line shouldn't fail records ", err)
+	}
+	if len(records) != 2 || records["map1"].Name != "First" || records["map2"].Name != "Second" {
+		t.Error("Valid records should be kept ", records)
+	}
+	if !strings.Contains(logs.String(), "line=3") || !strings.Contains(logs.String(), "offset=") {
+		t.Error("Corrupt line should be logged ", logs.String())
+	}
+}
+
+func BenchmarkReadCaptimeRecords(b *testing.B) {
+	path := writeTestGameDB(b, 500)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ReadCaptimeRecordsWithFilter([]string{path}, func(key, value string) bool { return true })
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/gamedb"
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"gopkg.in/yaml.v2"
+)
+
+var configFile = flag.String("config", "", "Backend config, servers from it are pinged to check that they are stopped")
+var force = flag.Bool("force", false, "Modify database without checking that servers are stopped")
+var noBackup = flag.Bool("noBackup", false, "Don't make backup before modifying database")
+
+const usage = `Usage: %s [flags] command db [args...]
+
+Commands:
+  delete-map db map...          remove all records of maps
+  remove-player db idfp...      remove records of players and renumber rankings
+  renumber db [map...]          close gaps in cts and race rankings
+  merge db other...             merge other databases into db keeping best times
+  verify db...                  check databases integrity
+
+Game server keeps database in memory and overwrites it on map change,
+so databases should be modified only when servers are stopped.
+
+Flags:
+`
+
+// checkServersStopped pings servers from backend config
+func checkServersStopped() error {
+	var conf struct {
+		Servers map[string]rcon.ServerConfig `yaml:"servers"`
+	}
+
+	if *force {
+		return nil
+	}
+	if *configFile == "" {
+		return errors.New("pass -config to check that servers are stopped or -force")
+	}
+	data, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		return err
+	}
+	// only server addresses are needed, so variables are expanded without backend's checks
+	if err = yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &conf); err != nil {
+		return err
+	}
+	for name, serverConf := range conf.Servers {
+		_, err := rcon.PingServer(&serverConf, time.Now().Add(time.Millisecond*500))
+		if err == nil {
+			return fmt.Errorf("server %s is running, stop it before modifying database", name)
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// modify runs fn on database and writes it back after backup
+func modify(path string, fn func(db *gamedb.DB) error) error {
+	if err := checkServersStopped(); err != nil {
+		return err
+	}
+	db, err := gamedb.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err = fn(db); err != nil {
+		return err
+	}
+	if !*noBackup {
+		backup := fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+		if err = copyFile(path, backup); err != nil {
+			return fmt.Errorf("can't make backup: %w", err)
+		}
+		fmt.Printf("Backup is saved to %s\n", backup)
+	}
+	return db.WriteFile(path)
+}
+
+func deleteMap(path string, maps []string) error {
+	return modify(path, func(db *gamedb.DB) error {
+		for _, mapname := range maps {
+			fmt.Printf("%s: removed %d keys\n", mapname, db.DeleteMap(mapname))
+		}
+		return nil
+	})
+}
+
+func removePlayer(path string, players []string) error {
+	return modify(path, func(db *gamedb.DB) error {
+		for _, idfp := range players {
+			fmt.Printf("%s: removed %d records\n", idfp, db.RemovePlayer(idfp))
+		}
+		return nil
+	})
+}
+
+func renumber(path string, maps []string) error {
+	return modify(path, func(db *gamedb.DB) error {
+		if len(maps) == 0 {
+			seen := make(map[string]bool)
+			for _, set := range gamedb.RankingSets {
+				for _, mapname := range db.RankedMaps(set) {
+					if !seen[mapname] {
+						seen[mapname] = true
+						maps = append(maps, mapname)
+					}
+				}
+			}
+		}
+		for _, mapname := range maps {
+			if db.Renumber(mapname) {
+				fmt.Printf("%s: renumbered\n", mapname)
+			}
+		}
+		return nil
+	})
+}
+
+func merge(path string, others []string) error {
+	return modify(path, func(db *gamedb.DB) error {
+		for _, otherPath := range others {
+			other, err := gamedb.ReadFile(otherPath)
+			if err != nil {
+				return err
+			}
+			db.Merge(other)
+		}
+		return nil
+	})
+}
+
+func verify(paths []string) error {
+	failed := false
+	for _, path := range paths {
+		db, err := gamedb.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		problems := append(db.Verify(), db.VerifyRecords()...)
+		for _, problem := range problems {
+			fmt.Printf("%s: %v\n", path, problem)
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s: ok\n", path)
+		} else {
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+func main() {
+	var err error
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	command, path, rest := args[0], args[1], args[2:]
+	if len(rest) == 0 && (command == "delete-map" || command == "remove-player" || command == "merge") {
+		flag.Usage()
+		os.Exit(1)
+	}
+	switch command {
+	case "delete-map":
+		err = deleteMap(path, rest)
+	case "remove-player":
+		err = removePlayer(path, rest)
+	case "renumber":
+		err = renumber(path, rest)
+	case "merge":
+		err = merge(path, rest)
+	case "verify":
+		err = verify(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type GeoIPState struct {
+	dbPath  func() string
+	lock    sync.Mutex
+	path    string
+	modTime time.Time
+	reader  *maxminddb.Reader
+}
+
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// getReader returns reader for configured database, database is reopened
+// when path is changed in config or file is updated, lock should be held
+func (s *GeoIPState) getReader() *maxminddb.Reader {
+	path := s.dbPath()
+	if path == "" {
+		s.close()
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		slog.Error("Can't open geoip database", "error", err)
+		s.close()
+		return nil
+	}
+	if s.reader != nil && s.path == path && !stat.ModTime().After(s.modTime) {
+		return s.reader
+	}
+	s.close()
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		slog.Error("Can't open geoip database", "error", err)
+		return nil
+	}
+	s.reader = reader
+	s.path = path
+	s.modTime = stat.ModTime()
+	return s.reader
+}
+
+func (s *GeoIPState) close() {
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+}
+
+// AnnotateCountries fills country code of players if geoip database is configured
+func (s *GeoIPState) AnnotateCountries(status *rcon.ServerStatus) {
+	if status == nil || len(status.Players) == 0 {
+		return
+	}
+	// reader can't be closed while we are using it
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	reader := s.getReader()
+	if reader == nil {
+		return
+	}
+	for i := range status.Players {
+		var record geoIPRecord
+
+		ip := net.ParseIP(status.Players[i].IP)
+		if ip == nil {
+			continue
+		}
+		err := reader.Lookup(ip, &record)
+		if err != nil {
+			slog.Warn("GeoIP lookup error", "error", err)
+			continue
+		}
+		status.Players[i].Country = record.Country.ISOCode
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	unixPrefix    = "unix:"
+	systemdPrefix = "systemd"
+	// first file descriptor passed by systemd socket activation
+	listenFDsStart = 3
+)
+
+var (
+	activationOnce      sync.Once
+	activationListeners map[string][]net.Listener
+	activationErr       error
+)
+
+// listen opens listener for address like host:port, unix:/path/to.sock, systemd or systemd:name,
+// systemd listeners are taken from socket activation by FileDescriptorName
+func listen(address string, socketMode os.FileMode) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, unixPrefix):
+		return listenUnix(strings.TrimPrefix(address, unixPrefix), socketMode)
+	case address == systemdPrefix || strings.HasPrefix(address, systemdPrefix+":"):
+		name := strings.TrimPrefix(strings.TrimPrefix(address, systemdPrefix), ":")
+		return systemdListener(name)
+	}
+	return net.Listen("tcp", address)
+}
+
+// listenUnix removes stale socket left after unclean exit and creates new one
+func listenUnix(path string, socketMode os.FileMode) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// systemdListener returns activated socket with name, default name of systemd is "unknown",
+// so empty name matches any socket when only one socket is passed
+func systemdListener(name string) (net.Listener, error) {
+	activationOnce.Do(func() {
+		activationListeners, activationErr = socketActivation(os.Getenv, listenFDsStart)
+		// sockets shouldn't be inherited by child processes
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if activationErr != nil {
+		return nil, activationErr
+	}
+	if name == "" && len(activationListeners) == 1 {
+		for key := range activationListeners {
+			name = key
+		}
+	}
+	listeners := activationListeners[name]
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("systemd socket %q wasn't passed", name)
+	}
+	activationListeners[name] = listeners[1:]
+	return listeners[0], nil
+}
+
+// socketActivation reads sockets passed by systemd, they are grouped by name from LISTEN_FDNAMES
+func socketActivation(getenv func(string) string, firstFD int) (map[string][]net.Listener, error) {
+	if getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, errors.New("socket activation isn't used, LISTEN_PID doesn't match")
+	}
+	count, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+	var names []string
+	if value := getenv("LISTEN_FDNAMES"); value != "" {
+		names = strings.Split(value, ":")
+	}
+	listeners := make(map[string][]net.Listener)
+	for i := 0; i < count; i++ {
+		fd := firstFD + i
+		syscall.CloseOnExec(fd)
+		file := os.NewFile(uintptr(fd), "systemd-socket-"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		// FileListener duplicates descriptor
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket %d: %w", fd, err)
+		}
+		name := "unknown"
+		if i < len(names) {
+			name = names[i]
+		}
+		listeners[name] = append(listeners[name], listener)
+	}
+	return listeners, nil
+}
+
+// apiListener is HTTP server with its listener, certificates are nil for plain HTTP
+type apiListener struct {
+	server   *http.Server
+	listener net.Listener
+	certs    *CertReloader
+}
+
+// newAPIListener opens listener for address, TLS isn't supported for unix sockets
+func newAPIListener(address string, scope routeScope, certs *CertReloader) (*apiListener, error) {
+	listener, err := listen(address, os.FileMode(*socketMode))
+	if err != nil {
+		return nil, err
+	}
+	if certs != nil && listener.Addr().Network() == "unix" {
+		listener.Close()
+		return nil, fmt.Errorf("TLS isn't supported on unix socket %s, remove -tls-cert or listen on TCP", address)
+	}
+	server := &http.Server{Handler: newRouter(scope)}
+	if certs != nil {
+		server.TLSConfig = certs.TLSConfig()
+	}
+	return &apiListener{server: server, listener: listener, certs: certs}, nil
+}
+
+func (l *apiListener) Serve() error {
+	slog.Info("Listening", "address", l.listener.Addr().String(), "network", l.listener.Addr().Network(), "tls", l.certs != nil)
+	if l.certs != nil {
+		return l.server.ServeTLS(l.listener, "", "")
+	}
+	return l.server.Serve(l.listener)
+}
+
+// CertReloader serves certificate that can be replaced without restarting listener
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Reload reads certificate and key again, old certificate is kept when they are invalid
+func (c *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert.Store(&cert)
+	return nil
+}
+
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+// TLSConfig returns config for http.Server, HTTP/2 is enabled by ServeTLS
+func (c *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: c.GetCertificate,
+	}
+}
+
+func (c *CertReloader) reloadOnSignal() {
+	if err := c.Reload(); err != nil {
+		slog.Error("Can't reload TLS certificate, old certificate is used", "cert", c.certFile, "error", err)
+		return
+	}
+	slog.Info("TLS certificate reloaded", "cert", c.certFile)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	// stale socket left after crash
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listen(unixPrefix+path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Error("Incorrect socket permissions ", info, err)
+	}
+	go http.Serve(listener, newRouter(publicRoutes))
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/api/v1/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Error("Incorrect status ", resp.StatusCode)
+	}
+
+	regular := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(regular, nil, 0600)
+	if _, err := listen(unixPrefix+regular, 0600); err == nil {
+		t.Error("Regular file shouldn't be replaced")
+	}
+}
+
+func TestSocketActivation(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// socketActivation owns passed descriptor and closes it like descriptors passed by systemd
+	fd, err := syscall.Dup(int(file.Fd()))
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"LISTEN_PID":     strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS":     "1",
+		"LISTEN_FDNAMES": "api",
+	}
+	listeners, err := socketActivation(func(name string) string { return env[name] }, fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners["api"]) != 1 || listeners["api"][0].Addr().String() != tcp.Addr().String() {
+		t.Fatal("Incorrect listeners ", listeners)
+	}
+	listeners["api"][0].Close()
+
+	env["LISTEN_PID"] = "1"
+	if _, err := socketActivation(func(name string) string { return env[name] }, 0); err == nil {
+		t.Error("Sockets of other process shouldn't be used")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "old.example.com")
+	certs, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		cert, _ := certs.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	writeTestCert(t, certFile, keyFile, "new.example.com")
+	if commonName() != "old.example.com" {
+		t.Error("Certificate shouldn't change before reload")
+	}
+	if err := certs.Reload(); err != nil || commonName() != "new.example.com" {
+		t.Error("Certificate wasn't reloaded ", err)
+	}
+	os.WriteFile(keyFile, []byte("broken"), 0600)
+	if err := certs.Reload(); err == nil || commonName() != "new.example.com" {
+		t.Error("Broken certificate should be ignored ", err)
+	}
+}
+
+func TestTLSListener(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "localhost")
+	certs, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := newAPIListener("127.0.0.1:0", publicRoutes, certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.Serve()
+	defer l.server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + l.listener.Addr().String() + "/api/v1/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Error("Incorrect response ", resp.StatusCode, resp.Proto)
+	}
+}
+
+func TestTLSUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "localhost")
+	certs, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "backend.sock")
+	if _, err := newAPIListener(unixPrefix+socket, publicRoutes, certs); err == nil {
+		t.Error("TLS on unix socket should be rejected")
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Error("Socket should be removed ", err)
+	}
+}
+
+func TestOpenListenersScopes(t *testing.T) {
+	defer func(listen, adminListen string) {
+		*listenAddr, *adminListenAddr = listen, adminListen
+	}(*listenAddr, *adminListenAddr)
+
+	get := func(l *apiListener, target string) int {
+		w := httptest.NewRecorder()
+		l.server.Handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w.Code
+	}
+	*listenAddr, *adminListenAddr = "127.0.0.1:0", ""
+	listeners, err := openListeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listeners[0].listener.Close()
+	if len(listeners) != 1 {
+		t.Error("Admin listener should be disabled ", len(listeners))
+	}
+	for _, target := range []string{"/metrics?target=ctf", "/internal/metrics", "/api/v1/admin/config"} {
+		if code := get(listeners[0], target); code != http.StatusNotFound {
+			t.Errorf("%s shouldn't be served by public listener, got %d", target, code)
+		}
+	}
+
+	*adminListenAddr = unixPrefix + filepath.Join(t.TempDir(), "admin.sock")
+	listeners, err = openListeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range listeners {
+		l.listener.Close()
+	}
+	if len(listeners) != 2 || get(listeners[0], "/internal/metrics") != http.StatusNotFound ||
+		get(listeners[1], "/internal/metrics") != http.StatusOK {
+		t.Error("Metrics should be served only by admin listener")
+	}
+}
+
+func TestRouteScopes(t *testing.T) {
+	cases := []struct {
+		scope  routeScope
+		target string
+		status int
+	}{
+		{publicRoutes, "/api/v1/openapi.json", http.StatusOK},
+		{publicRoutes, "/internal/metrics", http.StatusNotFound},
+		{publicRoutes, "/api/v1/admin/config", http.StatusNotFound},
+		{publicRoutes, "/metrics", http.StatusNotFound},
+		{adminRoutes, "/internal/metrics", http.StatusOK},
+		{adminRoutes, "/api/v1/openapi.json", http.StatusNotFound},
+		{allRoutes, "/internal/metrics", http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		newRouter(c.scope).ServeHTTP(w, httptest.NewRequest("GET", c.target, nil))
+		if w.Code != c.status {
+			t.Errorf("%s on scope %d: expected %d, got %d", c.target, c.scope, c.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+)
+
+// newLogger creates logger for -log-level and -log-format flags
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+
+	if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("Invalid log level %q", level)
+	}
+	options := &slog.HandlerOptions{Level: slogLevel}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("Invalid log format %q", format)
+}
+
+// setupLogging replaces default logger, it's used by package log too
+func setupLogging(w io.Writer, level, format string) error {
+	logger, err := newLogger(w, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	rcon.SetLogger(logger.With("component", "rcon"))
+	return nil
+}
+
+// statusWriter remembers status and size of response for access log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// accessLog logs completed requests, server errors are logged as warnings
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if sw.status >= 500 {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.status),
+			slog.Int("size", sw.size),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+			slog.String("request_id", requestID(r.Context())),
+		}
+		if server := chi.URLParam(r, "server"); server != "" {
+			attrs = append(attrs, slog.String("server", server))
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger, err := newLogger(&buf, "warn", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "server", "ctf")
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "shown" || record["server"] != "ctf" {
+		t.Error("Incorrect record ", record)
+	}
+	for _, args := range [][2]string{{"verbose", "text"}, {"info", "xml"}} {
+		if _, err := newLogger(&buf, args[0], args[1]); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	var record map[string]interface{}
+
+	logger, _ := newLogger(&buf, "info", "json")
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(defaultLogger)
+
+	serveTest("GET", "/api/v1/servers/missing/status", http.Header{"X-Request-Id": {"abc"}})
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "request" || record["status"] != float64(http.StatusNotFound) || record["request_id"] != "abc" ||
+		record["server"] != "missing" || record["path"] != "/api/v1/servers/missing/status" {
+		t.Error("Incorrect access log record ", record)
+	}
+	if _, ok := record["latency"]; !ok {
+		t.Error("Latency is missing ", record)
+	}
+}
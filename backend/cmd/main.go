@@ -11,13 +11,13 @@ import (
 	"html/template"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
-	"strings"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -33,6 +33,21 @@ type Config struct {
 	Servers map[string]rcon.ServerConfig `json:"servers,omitempty" yaml:"servers,omitempty"`
 	GameDB  []string                     `json:"gamedb,omitempty" yaml:"gamedb,omitempty"`
 	GameDIR []string                     `json:"gamedir,omitempty" yaml:"gamedir,omitempty"`
+	GeoIP   string                       `json:"geoip,omitempty" yaml:"geoip,omitempty"`
+	Cvars   []string                     `json:"cvars,omitempty" yaml:"cvars,omitempty"`
+	BanList string                       `json:"ban_list,omitempty" yaml:"ban_list,omitempty"`
+	// file for players count history, history is kept only in memory without it
+	Population string      `json:"population,omitempty" yaml:"population,omitempty"`
+	Chat       *ChatConfig `json:"chat,omitempty" yaml:"chat,omitempty"`
+	// player presence notifications, subscriptions API is disabled without it
+	Notifications *NotificationsConfig `json:"notifications,omitempty" yaml:"notifications,omitempty"`
+	RecordsFeed   *RecordsFeedConfig   `json:"records_feed,omitempty" yaml:"records_feed,omitempty"`
+	// origins allowed to use /api/v1 from browser
+	CORS *CORSConfig `json:"cors,omitempty" yaml:"cors,omitempty"`
+	// token for admin endpoints, admin endpoints are disabled without it
+	AdminToken rcon.Secret `json:"admin_token,omitempty" yaml:"admin_token,omitempty"`
+	// addresses or networks of reverse proxies, client address is taken from their headers
+	TrustedProxies []string `json:"trusted_proxies,omitempty" yaml:"trusted_proxies,omitempty"`
 }
 
 type ServerAll struct {
@@ -41,16 +56,44 @@ type ServerAll struct {
 	Scores *rcon.ServerScores `json:"scores"`
 }
 
+// ServerInfoV2 is /servers/{server}/info?version=2 response
+type ServerInfoV2 struct {
+	*rcon.ServerInfo
+	Mutators []string `json:"mutators"`
+}
+
 //go:embed config_schema.json
 var configSchema string
 
 var serverPort = flag.Int("port", 8080, "HTTP Server port")
 var serverHost = flag.String("addr", "", "Listen ip, empty by default")
 var shutdownTimeout = flag.Duration("shutdownTimeout", time.Second*10, "Timeout for gracefull shutdown")
+var watchInterval = flag.Duration("watchConfig", 0, "Interval for checking config file changes, disabled by default")
+var checkConfig = flag.Bool("check", false, "Validate config and exit")
+var chatListen = flag.String("chatListen", "", "UDP address for receiving server logs for chat relay, disabled by default")
+var banSyncInterval = flag.Duration("banSync", time.Minute, "Interval for applying shared ban list to servers, 0 disables it")
+var populationInterval = flag.Duration("populationInterval", time.Minute, "Interval for sampling players count history, 0 disables it")
+var recordsInterval = flag.Duration("recordsInterval", time.Minute, "Interval for checking gamedb for broken records, 0 disables it")
+var notifyInterval = flag.Duration("notifyInterval", time.Second*30, "Interval for checking players count for notifications, 0 disables it")
+var logLevel = flag.String("log-level", "info", "Log level: debug, info, warn or error")
+var logFormat = flag.String("log-format", "text", "Log format: text or json")
+var listenAddr = flag.String("listen", "", "Listen address: host:port, unix:/path/to.sock or systemd[:name] for socket activation, -addr and -port are used by default")
+var adminListenAddr = flag.String("admin-listen", "127.0.0.1:8081", "Listen address for admin and metrics routes, they are never served by -listen, empty disables them")
+var socketMode = flag.Uint("socket-mode", 0660, "Permissions of unix sockets")
+var tlsCert = flag.String("tls-cert", "", "TLS certificate file, it's reloaded on SIGHUP")
+var tlsKey = flag.String("tls-key", "", "TLS private key file")
+var dnsTTL = flag.Duration("dns-ttl", rcon.DefaultDNSTTL, "How long resolved addresses of servers are cached")
+var staticDir = flag.String("static", "", "Directory with built frontend, embedded frontend is served when it's empty")
 
 var config atomic.Value
 
 var mapsState *MapsState
+var geoIPState *GeoIPState
+var banList *BanList
+var chatRelay *ChatRelay
+var notifier *Notifier
+var populationRecorder *PopulationRecorder
+var recordFeed *RecordsFeed
 var viewTemplates = template.Must(template.Must(template.New("exporters").Parse(`
 <html>
   <head>
@@ -67,30 +110,31 @@ var viewTemplates = template.Must(template.Must(template.New("exporters").Parse(
 # server: {{ .Name }}
 {{if .Metrics.Status}}
 # hostname: {{ .Metrics.Status.Hostname }}
+# address: {{ .Metrics.Addr }}
 # map: powerstation_r2
-xonotic_sv_public{instance="{{ .Name }}"} {{ .Metrics.Status.Public }}
+xonotic_sv_public{{ .Labels }} {{ .Metrics.Status.Public }}
 
 # Players info
-xonotic_players_count{instance="{{ .Name }}"} {{ .Metrics.Status.PlayersActive }}
-xonotic_players_max{instance="{{ .Name }}"} {{ .Metrics.Status.PlayersMax }}
-xonotic_players_bots{instance="{{ .Name }}"} {{ .Metrics.PlayersInfo.Bots }}
-xonotic_players_spectators{instance="{{ .Name }}"} {{ .Metrics.PlayersInfo.Spectators }}
-xonotic_players_active{instance="{{ .Name }}"} {{ .Metrics.PlayersInfo.Active }}
+xonotic_players_count{{ .Labels }} {{ .Metrics.Status.PlayersActive }}
+xonotic_players_max{{ .Labels }} {{ .Metrics.Status.PlayersMax }}
+xonotic_players_bots{{ .Labels }} {{ .Metrics.PlayersInfo.Bots }}
+xonotic_players_spectators{{ .Labels }} {{ .Metrics.PlayersInfo.Spectators }}
+xonotic_players_active{{ .Labels }} {{ .Metrics.PlayersInfo.Active }}
 
 # Performance timings
-xonotic_timing_cpu{instance="{{ .Name }}"} {{ .Metrics.Status.Timing.CPU }}
-xonotic_timing_lost{instance="{{ .Name }}"} {{ .Metrics.Status.Timing.Lost }}
-xonotic_timing_offset_avg{instance="{{ .Name }}"} {{ .Metrics.Status.Timing.OffsetAvg }}
-xonotic_timing_max{instance="{{ .Name }}"} {{ .Metrics.Status.Timing.OffsetMax }}
-xonotic_timing_sdev{instance="{{ .Name }}"} {{ .Metrics.Status.Timing.OffsetSdev }}
+xonotic_timing_cpu{{ .Labels }} {{ .Metrics.Status.Timing.CPU }}
+xonotic_timing_lost{{ .Labels }} {{ .Metrics.Status.Timing.Lost }}
+xonotic_timing_offset_avg{{ .Labels }} {{ .Metrics.Status.Timing.OffsetAvg }}
+xonotic_timing_max{{ .Labels }} {{ .Metrics.Status.Timing.OffsetMax }}
+xonotic_timing_sdev{{ .Labels }} {{ .Metrics.Status.Timing.OffsetSdev }}
 {{end}}{{if .Metrics.Memory}}
 # Memory
-xonotic_memstats_pools_count{instance="{{ .Name }}"} {{ .Metrics.Memory.PoolsCount }}
-xonotic_memstats_pools_total{instance="{{ .Name }}"} {{ .Metrics.Memory.PoolsTotal }}
-xonotic_memstats_allocated_size{instance="{{ .Name }}"} {{ .Metrics.Memory.TotalAllocatedSize }}
+xonotic_memstats_pools_count{{ .Labels }} {{ .Metrics.Memory.PoolsCount }}
+xonotic_memstats_pools_total{{ .Labels }} {{ .Metrics.Memory.PoolsTotal }}
+xonotic_memstats_allocated_size{{ .Labels }} {{ .Metrics.Memory.TotalAllocatedSize }}
 {{end}}
 # Network rtt {{ .Metrics.PingDuration }}
-xonotic_rtt{instance="{{ .Name }}", from="{{ .Hostname }}"} {{ .Metrics.PingSeconds }}
+xonotic_rtt{{ .RTTLabels }} {{ .Metrics.PingSeconds }}
 `))
 
 func healthz(w http.ResponseWriter, r *http.Request) {
@@ -101,48 +145,13 @@ func generateEtag(data []byte) string {
 	return fmt.Sprintf("%x", md5.Sum(data))
 }
 
-func records(w http.ResponseWriter, r *http.Request) {
-	conf := getConfig()
-	mapsSet := mapsState.GetMapsSet()
-	filter := func(key, value string) bool {
-		i := strings.Index(key, "/")
-		if i >= 0 {
-			mapname := key[:i]
-			if _, ok := mapsSet[mapname]; ok {
-				return true
-			} else {
-				return false
-			}
-		} else {
-			return false
-		}
-	}
-	records, err := ReadCaptimeRecordsWithFilter(conf.GameDB, filter)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	json, err := json.Marshal(records)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func servers(w http.ResponseWriter, r *http.Request) {
+	if expand := r.FormValue("expand"); expand != "" {
+		serversExpanded(w, r, expand)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Etag", generateEtag(json))
-	w.Write(json)
-}
-
-func servers(w http.ResponseWriter, r *http.Request) {
-	var servers []string
-
 	conf := getConfig()
-	for k := range conf.Servers {
-		servers = append(servers, k)
-	}
-	json, err := json.Marshal(struct {
-		Servers []string `json:"servers"`
-	}{servers})
+	json, err := json.Marshal(newServersList(conf.Servers))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -154,7 +163,7 @@ func servers(w http.ResponseWriter, r *http.Request) {
 func server(w http.ResponseWriter, r *http.Request) {
 	conf := getConfig()
 	serverName := chi.URLParam(r, "server")
-	serverConf, ok := conf.Servers[serverName]
+	serverConf, ok := conf.PublicServer(serverName)
 	if !ok {
 		http.Error(w, "Server not found", http.StatusNotFound)
 		return
@@ -168,6 +177,7 @@ func server(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Can't load data from server", http.StatusInternalServerError)
 		return
 	}
+	geoIPState.AnnotateCountries(status)
 	json, err := json.Marshal(status)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -183,7 +193,7 @@ func info(w http.ResponseWriter, r *http.Request) {
 
 	conf := getConfig()
 	serverName := chi.URLParam(r, "server")
-	serverConf, ok := conf.Servers[serverName]
+	serverConf, ok := conf.PublicServer(serverName)
 	if !ok {
 		http.Error(w, "Server not found", http.StatusNotFound)
 		return
@@ -196,7 +206,22 @@ func info(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Can't load data from server", http.StatusInternalServerError)
 		return
 	}
-	json, err := json.Marshal(info)
+	var response interface{} = info
+	if r.FormValue("version") == "2" {
+		mutators, err := rcon.QueryWithRetries(time.Millisecond*1000, 3,
+			func(deadline time.Time) ([]string, error) {
+				return rcon.QueryMutators(&serverConf, deadline)
+			})
+		if err != nil {
+			http.Error(w, "Can't load data from server", http.StatusInternalServerError)
+			return
+		}
+		response = ServerInfoV2{
+			ServerInfo: info,
+			Mutators:   mutators,
+		}
+	}
+	json, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -211,7 +236,7 @@ func scores(w http.ResponseWriter, r *http.Request) {
 
 	conf := getConfig()
 	serverName := chi.URLParam(r, "server")
-	serverConf, ok := conf.Servers[serverName]
+	serverConf, ok := conf.PublicServer(serverName)
 	if !ok {
 		http.Error(w, "Server not found", http.StatusNotFound)
 		return
@@ -242,7 +267,7 @@ func serverAll(w http.ResponseWriter, r *http.Request) {
 
 	conf := getConfig()
 	serverName := chi.URLParam(r, "server")
-	serverConf, ok := conf.Servers[serverName]
+	serverConf, ok := conf.PublicServer(serverName)
 	if !ok {
 		http.Error(w, "Server not found", http.StatusNotFound)
 		return
@@ -276,6 +301,7 @@ func serverAll(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Can't load data from server", http.StatusInternalServerError)
 		return
 	}
+	geoIPState.AnnotateCountries(status)
 	json, err := json.Marshal(ServerAll{
 		ServerStatus: status,
 		Info:         info,
@@ -292,10 +318,11 @@ func serverAll(w http.ResponseWriter, r *http.Request) {
 func exporters(w http.ResponseWriter, r *http.Request) {
 	var servers []string
 
-	conf := getConfig()
-	for k := range conf.Servers {
-		servers = append(servers, k)
+	// page is served with metrics by admin listener, so internal servers are listed too
+	for name := range getConfig().Servers {
+		servers = append(servers, name)
 	}
+	sort.Strings(servers)
 	err := viewTemplates.ExecuteTemplate(w, "exporters", servers)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -310,9 +337,11 @@ func metrics(w http.ResponseWriter, r *http.Request) {
 	conf := getConfig()
 	server := r.FormValue("target")
 	templateContext := struct {
-		Name     string
-		Hostname string
-		Metrics  *rcon.ServerMetrics
+		Name      string
+		Hostname  string
+		Labels    template.HTML
+		RTTLabels template.HTML
+		Metrics   *rcon.ServerMetrics
 	}{
 		Name:     server,
 		Hostname: "",
@@ -334,6 +363,8 @@ func metrics(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		goto ErrorHandler
 	}
+	templateContext.Labels = exporterLabels(server, serverConf.Labels, nil)
+	templateContext.RTTLabels = exporterLabels(server, serverConf.Labels, map[string]string{"from": templateContext.Hostname})
 	err = viewTemplates.ExecuteTemplate(w, "metrics", templateContext)
 	if err != nil {
 		goto ErrorHandler
@@ -366,35 +397,72 @@ func validateConfig(conf *Config) bool {
 	documentLoader := gojsonschema.NewGoLoader(conf)
 	res, err := schema.Validate(documentLoader)
 	if err != nil {
-		log.Printf("Error validating config: %v", err)
+		slog.Error("Error validating config", "error", err)
 		return false
 	}
 	if !res.Valid() {
 		for _, err := range res.Errors() {
-			log.Println(err)
+			slog.Error("Invalid config", "field", err.Field(), "error", err.Description())
 		}
 		return false
 	}
 	return true
 }
 
-func loadConfig(filename string) (*Config, bool) {
+// parseConfig reads and validates config without loading secrets
+func parseConfig(filename string) (*Config, bool) {
 	var config Config
+	var raw interface{}
 
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
-		log.Printf("Error reading config: %v", err)
+		slog.Error("Error reading config", "error", err)
 		return nil, false
 	}
-	err = yaml.Unmarshal(data, &config)
+	err = yaml.Unmarshal(data, &raw)
 	if err != nil {
-		log.Printf("Error parsing yaml config: %v", err)
+		slog.Error("Error parsing yaml config", "error", err)
 		return nil, false
 	}
+	raw, err = interpolateEnv(raw)
+	if err != nil {
+		slog.Error("Error interpolating config", "error", err)
+		return nil, false
+	}
+	// interpolated values are encoded again, so they are quoted when it's needed
+	data, err = yaml.Marshal(raw)
+	if err == nil {
+		err = yaml.Unmarshal(data, &config)
+	}
+	if err != nil {
+		slog.Error("Error parsing yaml config", "error", err)
+		return nil, false
+	}
+	for name, serverConf := range config.Servers {
+		serverConf.Name = name
+		config.Servers[name] = serverConf
+	}
 	ok := validateConfig(&config)
+	if _, err := parseTrustedProxies(config.TrustedProxies); err != nil {
+		slog.Error("Invalid config", "field", "trusted_proxies", "error", err)
+		ok = false
+	}
 	return &config, ok
 }
 
+func loadConfig(filename string) (*Config, bool) {
+	config, ok := parseConfig(filename)
+	if !ok {
+		return config, false
+	}
+	err := resolveSecrets(config)
+	if err != nil {
+		slog.Error("Error loading secrets", "error", err)
+		return config, false
+	}
+	return config, true
+}
+
 func getConfig() *Config {
 	val := config.Load()
 	if val == nil {
@@ -409,21 +477,145 @@ func getConfig() *Config {
 	return conf
 }
 
-func webService() http.Handler {
+// routeScope selects routes served by listener, admin routes can be moved to separate listener
+type routeScope int
+
+const (
+	publicRoutes routeScope = 1 << iota
+	adminRoutes
+	allRoutes = publicRoutes | adminRoutes
+)
+
+// apiRoutes registers API routes, they are mounted at /api/v1 and at root for compatibility
+func apiRoutes(r chi.Router, scope routeScope) {
+	if scope&publicRoutes != 0 {
+		publicAPIRoutes(r)
+	}
+	if scope&adminRoutes != 0 {
+		adminAPIRoutes(r)
+	}
+}
+
+func publicAPIRoutes(r chi.Router) {
+	r.With(cacheControl(cacheNone)).Get("/healthz", healthz)
+	r.With(cacheControl(cacheLong)).Get("/openapi.json", openAPI)
+	r.Group(func(r chi.Router) {
+		r.Use(cacheControl(cacheShort))
+		r.Get("/records", records)
+		r.Get("/records/feed", recordsFeed)
+		r.Get("/servers", servers)
+		r.Get("/servers/{server}/population", population)
+		r.Get("/maps", maps)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(cacheControl(cacheLive))
+		r.Get("/servers/{server}", serverAll)
+		r.Get("/servers/{server}/status", server)
+		r.Get("/servers/{server}/info", info)
+		r.Get("/servers/{server}/scores", scores)
+		r.Get("/servers/{server}/cvars", cvars)
+		r.Get("/servers/{server}/rotation", rotation)
+	})
+	r.With(cacheControl(cacheLong)).Get("/servers/{server}/population/heatmap", populationHeatmap)
+	r.Group(func(r chi.Router) {
+		r.Use(cacheControl(cacheNone))
+		r.With(chatAuth).Post("/servers/{server}/chat", sendChat)
+		r.Get("/subscriptions", subscriptions)
+		r.Post("/subscriptions", addSubscription)
+		r.Delete("/subscriptions/{id}", removeSubscription)
+	})
+	r.With(cacheControl(cacheLong)).Get("/subscriptions/vapid-key", vapidKey)
+}
+
+// adminAPIRoutes registers metrics and routes changing servers state
+func adminAPIRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(cacheControl(cacheNone))
+		r.With(adminAuth).Put("/servers/{server}/rotation", updateRotation)
+		r.Get("/metrics", metrics)
+		r.Get("/exporters", exporters)
+	})
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(cacheControl(cacheNone), adminAuth)
+		r.Get("/config", adminConfig)
+		r.Get("/bans", sharedBans)
+		r.Post("/bans", addSharedBan)
+		r.Delete("/bans/{address}", removeSharedBan)
+		r.Get("/servers/{server}/bans", serverBans)
+		r.Post("/servers/{server}/bans", addServerBan)
+		r.Delete("/servers/{server}/bans/{address}", removeServerBan)
+	})
+}
+
+// newRouter creates handler for listener serving routes of scope
+func newRouter(scope routeScope) http.Handler {
 	r := chi.NewRouter()
-	r.Get("/healthz", healthz)
-	r.Get("/records", records)
-	r.Get("/servers", servers)
-	r.Get("/servers/{server}", serverAll)
-	r.Get("/servers/{server}/status", server)
-	r.Get("/servers/{server}/info", info)
-	r.Get("/servers/{server}/scores", scores)
-	r.Get("/exporters", exporters)
-	r.Get("/metrics", metrics)
-	r.Get("/maps", maps)
+	r.Use(withRequestID, accessLog, requestMetrics)
+	if scope&adminRoutes != 0 {
+		r.With(cacheControl(cacheNone)).Get("/internal/metrics", internalMetrics)
+	}
+	r.Route(apiPrefix, func(r chi.Router) {
+		r.Use(cors, jsonErrors)
+		apiRoutes(r, scope)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(deprecated)
+		apiRoutes(r, scope)
+	})
+	if scope&publicRoutes != 0 && frontendFS != nil {
+		// API routes take precedence over files
+		site := newFrontend(frontendFS)
+		r.Method(http.MethodGet, "/*", site)
+		r.Method(http.MethodHead, "/*", site)
+	}
 	return r
 }
 
+// webService serves all routes on single handler, listeners serve only routes of their scope
+func webService() http.Handler {
+	return newRouter(allRoutes)
+}
+
+// openListeners opens public listener and optional admin listener, public one is first
+func openListeners() ([]*apiListener, error) {
+	var certs *CertReloader
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return nil, errors.New("both -tls-cert and -tls-key are required for TLS")
+	}
+	if *tlsCert != "" {
+		var err error
+		if certs, err = NewCertReloader(*tlsCert, *tlsKey); err != nil {
+			return nil, err
+		}
+	}
+	address := *listenAddr
+	if address == "" {
+		address = net.JoinHostPort(*serverHost, strconv.Itoa(*serverPort))
+	}
+	if *adminListenAddr == address {
+		return nil, errors.New("-admin-listen should differ from public listen address")
+	}
+	// admin and metrics routes aren't exposed on public listener
+	public, err := newAPIListener(address, publicRoutes, certs)
+	if err != nil {
+		return nil, err
+	}
+	listeners := []*apiListener{public}
+	if *adminListenAddr == "" {
+		slog.Warn("Admin and metrics routes are disabled, -admin-listen is empty")
+	} else {
+		// admin listener is expected on loopback or unix socket, so it's plain HTTP
+		admin, err := newAPIListener(*adminListenAddr, adminRoutes, nil)
+		if err != nil {
+			public.listener.Close()
+			return nil, err
+		}
+		listeners = append(listeners, admin)
+	}
+	return listeners, nil
+}
+
 func main() {
 	var filename string
 
@@ -434,6 +626,19 @@ func main() {
 		os.Exit(1)
 	}
 	filename = flag.Arg(0)
+	if err := setupLogging(os.Stderr, *logLevel, *logFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *checkConfig {
+		// secrets are loaded too, so missing password files are reported
+		if _, ok := loadConfig(filename); !ok {
+			fmt.Println("Configuration is invalid")
+			os.Exit(1)
+		}
+		fmt.Println("Configuration is valid")
+		os.Exit(0)
+	}
 	conf, ok := loadConfig(filename)
 	if !ok {
 		fmt.Println("Configuration is invalid")
@@ -445,10 +650,65 @@ func main() {
 	mapsState.gameDirs = func() []string {
 		return getConfig().GameDIR
 	}
+	geoIPState = new(GeoIPState)
+	geoIPState.dbPath = func() string {
+		return getConfig().GeoIP
+	}
+	banList = new(BanList)
+	banList.path = func() string {
+		return getConfig().BanList
+	}
+	chatRelay = NewChatRelay(func() *ChatConfig {
+		return getConfig().Chat
+	})
+	registerServerCache(chatRelay.Forget)
+	notifier = NewNotifier(func() *NotificationsConfig {
+		return getConfig().Notifications
+	})
+	registerServerCache(notifier.Forget)
+	populationRecorder = NewPopulationRecorder(func() string {
+		return getConfig().Population
+	})
+	recordFeed = NewRecordsFeed(func() *RecordsFeedConfig {
+		return getConfig().RecordsFeed
+	})
 
-	listenAddr := net.JoinHostPort(*serverHost, strconv.Itoa(*serverPort))
-	server := http.Server{Addr: listenAddr, Handler: webService()}
+	if *staticDir != "" {
+		frontendFS = os.DirFS(*staticDir)
+	} else {
+		frontendFS = embeddedFrontend()
+	}
+	rcon.SetResolver(net.DefaultResolver, *dnsTTL)
+	registerRuntimeMetrics()
+
+	listeners, err := openListeners()
+	if err != nil {
+		slog.Error("Can't start server", "error", err)
+		os.Exit(1)
+	}
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
+	if *watchInterval > 0 {
+		go watchConfig(serverCtx, filename, *watchInterval)
+	}
+	if *banSyncInterval > 0 {
+		go syncBans(serverCtx, *banSyncInterval)
+	}
+	if *populationInterval > 0 {
+		go populationRecorder.Run(serverCtx, *populationInterval)
+	}
+	if *recordsInterval > 0 {
+		go recordFeed.Run(serverCtx, *recordsInterval)
+	}
+	if *notifyInterval > 0 {
+		go notifier.Run(serverCtx, *notifyInterval)
+	}
+	if *chatListen != "" {
+		go func() {
+			if err := chatRelay.Listen(serverCtx, *chatListen); err != nil {
+				slog.Error("Chat relay stopped", "error", err)
+			}
+		}()
+	}
 
 	go func() {
 		sigHUP := make(chan os.Signal, 1)
@@ -459,24 +719,28 @@ func main() {
 		for {
 			select {
 			case <-sigHUP:
-				conf, ok := loadConfig(filename)
-				if !ok {
-					log.Println("Config wasn't updated because of errors")
-				} else {
-					config.Store(conf)
-					log.Println("Successfully updated config")
+				reloadConfig(filename)
+				if listeners[0].certs != nil {
+					listeners[0].certs.reloadOnSignal()
 				}
 			case <-sigQuit:
-				log.Println("Received gracefull shutdown event")
+				slog.Info("Received gracefull shutdown event")
 				shutdownCtx, cancel := context.WithTimeout(serverCtx, *shutdownTimeout)
 				go func() {
 					<-shutdownCtx.Done()
 					if shutdownCtx.Err() == context.DeadlineExceeded {
-						log.Fatal("graceful shutdown timed out")
+						slog.Error("Graceful shutdown timed out")
+						os.Exit(1)
 					}
 				}()
-				if err := server.Shutdown(shutdownCtx); err != nil {
-					log.Fatal(err)
+				for _, l := range listeners {
+					if err := l.server.Shutdown(shutdownCtx); err != nil {
+						slog.Error("Shutdown failed", "error", err)
+						os.Exit(1)
+					}
+				}
+				if err := populationRecorder.Save(); err != nil {
+					slog.Error("Can't save population history", "error", err)
 				}
 				serverStopCtx()
 				cancel()
@@ -485,8 +749,17 @@ func main() {
 		}
 	}()
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
+	serveErrs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l *apiListener) {
+			serveErrs <- l.Serve()
+		}(l)
+	}
+	for range listeners {
+		if err := <-serveErrs; err != nil && err != http.ErrServerClosed {
+			slog.Error("Can't start server", "error", err)
+			os.Exit(1)
+		}
 	}
 	// wait till all requests is done
 	<-serverCtx.Done()
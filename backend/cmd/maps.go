@@ -3,7 +3,7 @@ package main
 import (
 	"archive/zip"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"path"
 	"regexp"
 	"sort"
@@ -33,7 +33,7 @@ func (s *MapsState) GetMapsSet() map[string]bool {
 	for _, dir := range dirs {
 		files, err := ioutil.ReadDir(dir)
 		if err != nil {
-			log.Printf("Error reading dir: %v", err)
+			slog.Error("Error reading dir", "error", err)
 			continue
 		}
 		for _, file := range files {
@@ -63,8 +63,11 @@ func (s *MapsState) GetMapsSet() map[string]bool {
 			return true
 		}
 
-		if modtime.After(info.modTime) {
+		if !modtime.After(info.modTime) {
+			mapsCacheTotal.Inc("hit")
+		} else {
 			var newInfo PK3Info
+			mapsCacheTotal.Inc("miss")
 			// file was updated, so we need to update maps from this file
 			newInfo.maps = nil
 			callback := func(mapname string) bool {
@@ -74,7 +77,7 @@ func (s *MapsState) GetMapsSet() map[string]bool {
 			err := listPk3Maps(filepath, callback)
 			if err != nil {
 				// we can't load maps for this file
-				log.Printf("Can't load maps from %s %v", filepath, err)
+				slog.Warn("Can't load maps", "path", filepath, "error", err)
 				s.mapsCache.Delete(key)
 				delete(checkFiles, filepath)
 				return true
@@ -93,6 +96,7 @@ func (s *MapsState) GetMapsSet() map[string]bool {
 	s.mapsCache.Range(iterateStoredMaps)
 	for filepath, modtime := range checkFiles {
 		var newInfo PK3Info
+		mapsCacheTotal.Inc("miss")
 		newInfo.modTime = modtime
 		callback := func(mapname string) bool {
 			newInfo.maps = append(newInfo.maps, mapname)
@@ -100,9 +104,9 @@ func (s *MapsState) GetMapsSet() map[string]bool {
 		}
 		err := listPk3Maps(filepath, callback)
 		if err != nil {
-			log.Printf("Can't load maps from %s %v", filepath, err)
+			slog.Warn("Can't load maps", "path", filepath, "error", err)
 		}
-		s.mapsCache.Store(filepath, newInfo)
+		s.mapsCache.Store(filepath, &newInfo)
 		for _, mapname := range newInfo.maps {
 			mapsFound[mapname] = true
 		}
@@ -0,0 +1,617 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHysteresis    = 1
+	maxUserSubscriptions = 20
+	vapidTokenLifetime   = time.Hour * 12
+	// subscriptions created from one ip address, burst and rate per second
+	subscribeBurst = 5
+	subscribeRate  = 1.0 / 60
+	// limiters are dropped when there are too many of them
+	maxSubscribeLimiters = 4096
+)
+
+// maxSubscriptions limits size of store, anyone can create subscription with new token
+var maxSubscriptions = 5000
+
+var errNotificationsDisabled = errors.New("Notifications aren't configured")
+var errSubscriptionsLimit = errors.New("Too many subscriptions")
+
+// carrier-grade NAT range isn't covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+type NotificationsConfig struct {
+	// file where subscriptions are stored
+	Store string `json:"store" yaml:"store"`
+	// players count should drop below threshold - hysteresis to notify again
+	Hysteresis *int `json:"hysteresis,omitempty" yaml:"hysteresis,omitempty"`
+	// webhook hosts allowed for subscriptions, any public host is allowed when empty
+	AllowedHosts []string `json:"allowed_hosts,omitempty" yaml:"allowed_hosts,omitempty"`
+	// base64url encoded P-256 private key for Web Push
+	VapidKey     rcon.Secret `json:"vapid_key,omitempty" yaml:"vapid_key,omitempty"`
+	VapidSubject string      `json:"vapid_subject,omitempty" yaml:"vapid_subject,omitempty"`
+}
+
+type PushSubscription struct {
+	Endpoint string `json:"endpoint"`
+}
+
+type Subscription struct {
+	ID        string            `json:"id"`
+	UserHash  string            `json:"user_hash,omitempty"`
+	Server    string            `json:"server"`
+	Threshold int               `json:"threshold"`
+	Webhook   string            `json:"webhook,omitempty"`
+	Push      *PushSubscription `json:"push,omitempty"`
+	Created   time.Time         `json:"created"`
+}
+
+type PresenceNotification struct {
+	Server    string `json:"server"`
+	Players   int    `json:"players"`
+	Threshold int    `json:"threshold"`
+	Content   string `json:"content"`
+}
+
+type SubscriptionStore struct {
+	path          func() string
+	lock          sync.Mutex
+	loadedPath    string
+	subscriptions []Subscription
+}
+
+// load reads subscriptions when path is changed, lock should be held
+func (s *SubscriptionStore) load() error {
+	var subscriptions []Subscription
+
+	path := s.path()
+	if path == "" {
+		return errNotificationsDisabled
+	}
+	if path == s.loadedPath {
+		return nil
+	}
+	err := readJSONFile(path, &subscriptions)
+	if err != nil {
+		return err
+	}
+	s.subscriptions = subscriptions
+	s.loadedPath = path
+	return nil
+}
+
+func (s *SubscriptionStore) List() ([]Subscription, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	result := make([]Subscription, len(s.subscriptions))
+	copy(result, s.subscriptions)
+	return result, nil
+}
+
+func (s *SubscriptionStore) UserList(userHash string) ([]Subscription, error) {
+	result := []Subscription{}
+	subscriptions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subscriptions {
+		if sub.UserHash == userHash {
+			result = append(result, sub)
+		}
+	}
+	return result, nil
+}
+
+func (s *SubscriptionStore) Add(sub Subscription) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if len(s.subscriptions) >= maxSubscriptions {
+		return errSubscriptionsLimit
+	}
+	count := 0
+	for _, item := range s.subscriptions {
+		if item.UserHash == sub.UserHash {
+			count++
+		}
+	}
+	if count >= maxUserSubscriptions {
+		return fmt.Errorf("%w, user can't have more than %d", errSubscriptionsLimit, maxUserSubscriptions)
+	}
+	s.subscriptions = append(s.subscriptions, sub)
+	return writeJSONFile(s.loadedPath, s.subscriptions)
+}
+
+func (s *SubscriptionStore) Remove(userHash, id string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.load(); err != nil {
+		return false, err
+	}
+	for i, sub := range s.subscriptions {
+		if sub.ID == id && sub.UserHash == userHash {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			return true, writeJSONFile(s.loadedPath, s.subscriptions)
+		}
+	}
+	return false, nil
+}
+
+type Notifier struct {
+	config func() *NotificationsConfig
+	store  *SubscriptionStore
+	client *http.Client
+	lock   sync.Mutex
+	// subscriptions which were notified and wait for players count to drop
+	fired map[string]map[string]bool
+	// limiters of subscriptions creation by client ip
+	limiters map[string]*rateLimiter
+}
+
+func NewNotifier(config func() *NotificationsConfig) *Notifier {
+	return &Notifier{
+		config: config,
+		store: &SubscriptionStore{path: func() string {
+			if conf := config(); conf != nil {
+				return conf.Store
+			}
+			return ""
+		}},
+		client:   newPublicClient(time.Second * 5),
+		fired:    make(map[string]map[string]bool),
+		limiters: make(map[string]*rateLimiter),
+	}
+}
+
+// publicAddress reports whether ip is public, notifications aren't sent to loopback,
+// private and link local networks
+func publicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// newPublicClient returns client which connects only to public addresses, address is
+// checked after resolution, so hostnames of internal network and redirects to them fail too
+func newPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+				return fmt.Errorf("Address %s isn't public", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// proxy would be checked instead of target
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// allowSubscribe limits subscriptions created from one ip address
+func (n *Notifier) allowSubscribe(r *http.Request) bool {
+	host := clientIP(r, getConfig().TrustedProxies)
+	n.lock.Lock()
+	limiter, ok := n.limiters[host]
+	if !ok {
+		if len(n.limiters) >= maxSubscribeLimiters {
+			n.limiters = make(map[string]*rateLimiter)
+		}
+		limiter = new(rateLimiter)
+		n.limiters[host] = limiter
+	}
+	n.lock.Unlock()
+	return limiter.Allow(subscribeRate, subscribeBurst)
+}
+
+// Forget drops notification state of server
+func (n *Notifier) Forget(server string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.fired, server)
+}
+
+// Observe returns subscriptions which should be notified about players count,
+// state isn't known on first observation, so nothing is returned for it
+func (n *Notifier) Observe(server string, players int, subscriptions []Subscription) []Subscription {
+	var result []Subscription
+
+	hysteresis := defaultHysteresis
+	if conf := n.config(); conf != nil && conf.Hysteresis != nil {
+		hysteresis = *conf.Hysteresis
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	fired, ok := n.fired[server]
+	if !ok {
+		fired = make(map[string]bool)
+		n.fired[server] = fired
+	}
+	for _, sub := range subscriptions {
+		if sub.Server != server {
+			continue
+		}
+		wasFired, known := fired[sub.ID]
+		if players >= sub.Threshold {
+			fired[sub.ID] = true
+			if known && !wasFired {
+				result = append(result, sub)
+			}
+		} else if !known || players < sub.Threshold-hysteresis {
+			fired[sub.ID] = false
+		}
+	}
+	return result
+}
+
+func (n *Notifier) poll() {
+	subscriptions, err := n.store.List()
+	if err != nil {
+		if err != errNotificationsDisabled {
+			slog.Error("Can't load subscriptions", "error", err)
+		}
+		return
+	}
+	conf := getConfig()
+	servers := make(map[string]bool)
+	for _, sub := range subscriptions {
+		servers[sub.Server] = true
+	}
+	for name := range servers {
+		serverConf, ok := conf.Servers[name]
+		if !ok {
+			continue
+		}
+		status, err := rcon.QueryWithRetries(time.Millisecond*1000, 3,
+			func(deadline time.Time) (*rcon.ServerStatus, error) {
+				return rcon.QueryRconStatus(&serverConf, deadline)
+			})
+		if err != nil {
+			continue
+		}
+		players := rcon.CountPlayers(status).Humans()
+		for _, sub := range n.Observe(name, players, subscriptions) {
+			if err := n.Notify(sub, players); err != nil {
+				slog.Warn("Can't notify subscription", "server", name, "subscription", sub.ID, "error", err)
+			}
+		}
+	}
+}
+
+// Run checks players count on servers with subscriptions
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.poll()
+		}
+	}
+}
+
+func (n *Notifier) Notify(sub Subscription, players int) error {
+	if sub.Webhook != "" {
+		data, err := json.Marshal(PresenceNotification{
+			Server:    sub.Server,
+			Players:   players,
+			Threshold: sub.Threshold,
+			Content:   fmt.Sprintf("%d players are online on %s", players, sub.Server),
+		})
+		if err != nil {
+			return err
+		}
+		return n.send(sub.Webhook, data, nil)
+	}
+	if sub.Push != nil {
+		conf := n.config()
+		if conf == nil || conf.VapidKey == "" {
+			return errors.New("Web Push isn't configured")
+		}
+		auth, err := vapidAuthorization(conf, sub.Push.Endpoint)
+		if err != nil {
+			return err
+		}
+		// push message without payload, service worker fetches servers status
+		return n.send(sub.Push.Endpoint, nil, map[string]string{
+			"Authorization": auth,
+			"TTL":           "3600",
+		})
+	}
+	return nil
+}
+
+func (n *Notifier) send(endpoint string, data []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+func vapidPrivateKey(conf *NotificationsConfig) (*ecdsa.PrivateKey, error) {
+	d, err := base64.RawURLEncoding.DecodeString(string(conf.VapidKey))
+	if err != nil || len(d) != 32 {
+		return nil, errors.New("VAPID key should be base64url encoded 32 bytes")
+	}
+	key := new(ecdsa.PrivateKey)
+	key.Curve = elliptic.P256()
+	key.D = new(big.Int).SetBytes(d)
+	key.X, key.Y = key.Curve.ScalarBaseMult(d)
+	return key, nil
+}
+
+func vapidPublicKey(key *ecdsa.PrivateKey) string {
+	return base64.RawURLEncoding.EncodeToString(elliptic.Marshal(key.Curve, key.X, key.Y))
+}
+
+// vapidAuthorization builds RFC 8292 authorization header for push service
+func vapidAuthorization(conf *NotificationsConfig, endpoint string) (string, error) {
+	key, err := vapidPrivateKey(conf)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidTokenLifetime).Unix(),
+		"sub": conf.VapidSubject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	jwt := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", jwt, vapidPublicKey(key)), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(auth, "Bearer ")
+}
+
+// validateNotifyURL allows only https urls to allowed hosts, urls with internal addresses
+// are rejected, resolved hostnames are checked by client of notifier
+func validateNotifyURL(conf *NotificationsConfig, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("Only https urls are allowed")
+	}
+	hostname := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ip := net.ParseIP(hostname); ip != nil && !publicAddress(ip) || hostname == "localhost" || strings.HasSuffix(hostname, ".localhost") {
+		return fmt.Errorf("Host %s isn't public", u.Hostname())
+	}
+	if len(conf.AllowedHosts) == 0 {
+		return nil
+	}
+	for _, host := range conf.AllowedHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Host %s isn't allowed", u.Hostname())
+}
+
+func writeNotificationsError(w http.ResponseWriter, err error) {
+	if err == errNotificationsDisabled {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	} else if errors.Is(err, errSubscriptionsLimit) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	} else {
+		slog.Error("Subscriptions error", "error", err)
+		http.Error(w, "Can't update subscriptions", http.StatusInternalServerError)
+	}
+}
+
+func publicSubscriptions(subscriptions []Subscription) []Subscription {
+	for i := range subscriptions {
+		subscriptions[i].UserHash = ""
+	}
+	return subscriptions
+}
+
+// subscriptions lists subscriptions of user identified by bearer token
+func subscriptions(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	result, err := notifier.store.UserList(hashToken(token))
+	if err != nil {
+		writeNotificationsError(w, err)
+		return
+	}
+	writeJSON(w, struct {
+		Subscriptions []Subscription `json:"subscriptions"`
+	}{publicSubscriptions(result)})
+}
+
+// addSubscription creates subscription, new user token is generated
+// when request doesn't have one
+func addSubscription(w http.ResponseWriter, r *http.Request) {
+	var sub Subscription
+
+	conf := getConfig()
+	if conf.Notifications == nil {
+		writeNotificationsError(w, errNotificationsDisabled)
+		return
+	}
+	if !notifier.allowSubscribe(r) {
+		http.Error(w, "Too many subscriptions, try again later", http.StatusTooManyRequests)
+		return
+	}
+	err := json.NewDecoder(r.Body).Decode(&sub)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, ok := conf.PublicServer(sub.Server); !ok {
+		http.Error(w, "Server not found", http.StatusBadRequest)
+		return
+	}
+	if sub.Threshold < 1 {
+		http.Error(w, "Threshold should be positive", http.StatusBadRequest)
+		return
+	}
+	if (sub.Webhook == "") == (sub.Push == nil) {
+		http.Error(w, "Either webhook or push subscription is required", http.StatusBadRequest)
+		return
+	}
+	target := sub.Webhook
+	if sub.Push != nil {
+		if conf.Notifications.VapidKey == "" {
+			http.Error(w, "Web Push isn't configured", http.StatusNotImplemented)
+			return
+		}
+		target = sub.Push.Endpoint
+	}
+	if err := validateNotifyURL(conf.Notifications, target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := bearerToken(r)
+	if token == "" {
+		token, err = randomToken(32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	sub.ID, err = randomToken(12)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sub.UserHash = hashToken(token)
+	sub.Created = time.Now().UTC()
+	err = notifier.store.Add(sub)
+	if err != nil {
+		writeNotificationsError(w, err)
+		return
+	}
+	sub.UserHash = ""
+	writeJSONStatus(w, http.StatusCreated, struct {
+		Token        string       `json:"token"`
+		Subscription Subscription `json:"subscription"`
+	}{token, sub})
+}
+
+func removeSubscription(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	found, err := notifier.store.Remove(hashToken(token), chi.URLParam(r, "id"))
+	if err != nil {
+		writeNotificationsError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// vapidKey returns public key for PushManager.subscribe
+func vapidKey(w http.ResponseWriter, r *http.Request) {
+	conf := getConfig().Notifications
+	if conf == nil || conf.VapidKey == "" {
+		http.Error(w, "Web Push isn't configured", http.StatusNotImplemented)
+		return
+	}
+	key, err := vapidPrivateKey(conf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, struct {
+		Key string `json:"key"`
+	}{vapidPublicKey(key)})
+}
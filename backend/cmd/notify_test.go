@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+func TestNotifierObserve(t *testing.T) {
+	hysteresis := 2
+	conf := &NotificationsConfig{Hysteresis: &hysteresis}
+	notifier := NewNotifier(func() *NotificationsConfig { return conf })
+	subs := []Subscription{
+		{ID: "a", Server: "pub", Threshold: 4},
+		{ID: "b", Server: "other", Threshold: 1},
+	}
+	steps := []struct {
+		players  int
+		notified int
+	}{
+		// first observation only initializes state
+		{5, 0},
+		{1, 0},
+		{4, 1},
+		{5, 0},
+		// players count didn't drop below threshold - hysteresis
+		{2, 0},
+		{4, 0},
+		{1, 0},
+		{4, 1},
+	}
+	for i, step := range steps {
+		notified := notifier.Observe("pub", step.players, subs)
+		if len(notified) != step.notified {
+			t.Errorf("Step %d: expected %d notifications, got %v", i, step.notified, notified)
+		}
+		for _, sub := range notified {
+			if sub.ID != "a" {
+				t.Errorf("Step %d: subscription of other server notified %v", i, sub)
+			}
+		}
+	}
+	notifier.Forget("pub")
+	if notified := notifier.Observe("pub", 5, subs); len(notified) != 0 {
+		t.Error("Server state wasn't forgotten ", notified)
+	}
+}
+
+func TestNotifierWebhook(t *testing.T) {
+	var received []PresenceNotification
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg PresenceNotification
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error("Invalid webhook payload ", err)
+		}
+		received = append(received, msg)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	notifier := NewNotifier(func() *NotificationsConfig { return nil })
+	// test server listens on loopback, which is rejected by default client
+	notifier.client = webhook.Client()
+	sub := Subscription{ID: "a", Server: "pub", Threshold: 4, Webhook: webhook.URL}
+	if err := notifier.Notify(sub, 6); err != nil {
+		t.Fatal("Error notifying subscription ", err)
+	}
+	if len(received) != 1 || received[0].Players != 6 || received[0].Server != "pub" {
+		t.Error("Incorrect notification received by webhook ", received)
+	}
+}
+
+func TestSubscriptionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	store := &SubscriptionStore{path: func() string { return path }}
+	if err := store.Add(Subscription{ID: "a", UserHash: hashToken("user1"), Server: "pub"}); err != nil {
+		t.Fatal("Error adding subscription ", err)
+	}
+	if err := store.Add(Subscription{ID: "b", UserHash: hashToken("user2"), Server: "pub"}); err != nil {
+		t.Fatal("Error adding subscription ", err)
+	}
+	// reload from disk
+	store = &SubscriptionStore{path: func() string { return path }}
+	subs, err := store.UserList(hashToken("user1"))
+	if err != nil || len(subs) != 1 || subs[0].ID != "a" {
+		t.Error("Incorrect user subscriptions ", subs, err)
+	}
+	if found, _ := store.Remove(hashToken("user1"), "b"); found {
+		t.Error("User removed subscription of other user")
+	}
+	if found, err := store.Remove(hashToken("user2"), "b"); !found || err != nil {
+		t.Error("Subscription wasn't removed ", err)
+	}
+}
+
+func TestVapidAuthorization(t *testing.T) {
+	conf := &NotificationsConfig{
+		VapidKey:     "xpV2gx1u4cHTuXM6Ex7S82d5aI7A1nLC3fQn5YL4vzg",
+		VapidSubject: "mailto:admin@example.com",
+	}
+	auth, err := vapidAuthorization(conf, "https://push.example.com/send/abc")
+	if err != nil {
+		t.Fatal("Error building VAPID authorization ", err)
+	}
+	if !strings.HasPrefix(auth, "vapid t=") || strings.Count(auth, ".") != 2 {
+		t.Error("Invalid VAPID authorization ", auth)
+	}
+	if _, err := vapidAuthorization(&NotificationsConfig{VapidKey: "short"}, "https://push.example.com"); err == nil {
+		t.Error("Invalid VAPID key should fail")
+	}
+}
+
+func TestValidateNotifyURL(t *testing.T) {
+	conf := &NotificationsConfig{AllowedHosts: []string{"discord.com"}}
+	if err := validateNotifyURL(conf, "https://discord.com/api/webhooks/1"); err != nil {
+		t.Error("Allowed url was rejected ", err)
+	}
+	if err := validateNotifyURL(conf, "http://discord.com/api/webhooks/1"); err == nil {
+		t.Error("Plain http url should be rejected")
+	}
+	if err := validateNotifyURL(conf, "https://example.com/hook"); err == nil {
+		t.Error("Url with not allowed host should be rejected")
+	}
+	internal := []string{"https://127.0.0.1/hook", "https://[::1]:8080/", "https://169.254.169.254/latest",
+		"https://10.0.0.5/", "https://100.64.1.1/", "https://localhost/", "https://app.localhost./"}
+	for _, rawURL := range internal {
+		if err := validateNotifyURL(&NotificationsConfig{}, rawURL); err == nil {
+			t.Error("Internal url should be rejected ", rawURL)
+		}
+	}
+	if err := validateNotifyURL(&NotificationsConfig{}, "https://1.1.1.1/hook"); err != nil {
+		t.Error("Public url was rejected ", err)
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request to loopback address shouldn't be sent")
+	}))
+	defer webhook.Close()
+
+	if _, err := newPublicClient(time.Second).Post(webhook.URL, "application/json", nil); err == nil {
+		t.Error("Loopback address should be rejected")
+	}
+}
+
+func TestSubscriptionLimits(t *testing.T) {
+	defer func(limit int) {
+		maxSubscriptions = limit
+	}(maxSubscriptions)
+	maxSubscriptions = 3
+
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	store := &SubscriptionStore{path: func() string { return path }}
+	for i := 0; i < 3; i++ {
+		if err := store.Add(Subscription{ID: strconv.Itoa(i), UserHash: hashToken(strconv.Itoa(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// every new token is separate user, so store has global limit
+	if err := store.Add(Subscription{ID: "new", UserHash: hashToken("new")}); !errors.Is(err, errSubscriptionsLimit) {
+		t.Error("Store limit should be applied ", err)
+	}
+
+	config.Store(&Config{})
+	notifier := NewNotifier(func() *NotificationsConfig { return nil })
+	req := httptest.NewRequest("POST", "/api/v1/subscriptions", nil)
+	for i := 0; i < subscribeBurst; i++ {
+		if !notifier.allowSubscribe(req) {
+			t.Fatal("Request should be allowed ", i)
+		}
+	}
+	if notifier.allowSubscribe(req) {
+		t.Error("Requests from same address should be limited")
+	}
+	req.RemoteAddr = "192.0.2.10:4000"
+	if !notifier.allowSubscribe(req) {
+		t.Error("Other address shouldn't be limited")
+	}
+}
+
+func TestSubscriptionLimitsBehindProxy(t *testing.T) {
+	config.Store(&Config{TrustedProxies: []string{"10.0.0.1"}})
+	notifier := NewNotifier(func() *NotificationsConfig { return nil })
+	request := func(client string) *http.Request {
+		req := httptest.NewRequest("POST", "/api/v1/subscriptions", nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		req.Header.Set("X-Forwarded-For", client)
+		return req
+	}
+	for i := 0; i < subscribeBurst; i++ {
+		notifier.allowSubscribe(request("192.0.2.1"))
+	}
+	if notifier.allowSubscribe(request("192.0.2.1")) {
+		t.Error("Requests of same client should be limited")
+	}
+	if !notifier.allowSubscribe(request("192.0.2.2")) {
+		t.Error("Other client behind proxy shouldn't be limited")
+	}
+}
+
+func TestAddSubscription(t *testing.T) {
+	conf := &NotificationsConfig{Store: filepath.Join(t.TempDir(), "subscriptions.json")}
+	config.Store(&Config{
+		Servers:       map[string]rcon.ServerConfig{"ctf": {Server: "127.0.0.1", Port: 26000}},
+		Notifications: conf,
+	})
+	defer func(previous *Notifier) {
+		notifier = previous
+	}(notifier)
+	notifier = NewNotifier(func() *NotificationsConfig { return conf })
+
+	body := `{"server": "ctf", "threshold": 2, "webhook": "https://example.com/hook"}`
+	req := httptest.NewRequest("POST", "/api/v1/subscriptions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	addSubscription(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatal("Incorrect status ", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Error("Incorrect content type ", w.Header().Get("Content-Type"))
+	}
+	validateOpenAPI(t, "SubscriptionCreated", json.RawMessage(w.Body.Bytes()))
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes all routes of webService, pkg/client is generated from it
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Etag", generateEtag(openAPISpec))
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPI(t *testing.T) *openAPIDocument {
+	var doc openAPIDocument
+
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return &doc
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	var routes, aliases, documented []string
+
+	err := chi.Walk(webService().(chi.Routes), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = method + " " + strings.ReplaceAll(route, "/*/", "/")
+		if strings.Contains(route, apiPrefix) {
+			routes = append(routes, strings.Replace(route, apiPrefix, "", 1))
+		} else if !strings.Contains(route, " /internal/") {
+			aliases = append(aliases, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, item := range loadOpenAPI(t).Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	sort.Strings(routes)
+	sort.Strings(aliases)
+	sort.Strings(documented)
+	if strings.Join(routes, "\n") != strings.Join(documented, "\n") {
+		t.Errorf("Routes and specification differ:\n%s\n\n%s", strings.Join(routes, "\n"), strings.Join(documented, "\n"))
+	}
+	if strings.Join(routes, "\n") != strings.Join(aliases, "\n") {
+		t.Errorf("Deprecated aliases differ from routes:\n%s", strings.Join(aliases, "\n"))
+	}
+}
+
+// jsonSchema converts OpenAPI schema to json schema, objects are closed,
+// so fields missing in specification are reported too
+func jsonSchema(schema interface{}, schemas map[string]interface{}) interface{} {
+	switch value := schema.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = jsonSchema(item, schemas)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for k, v := range value {
+			result[k] = v
+		}
+		if parts, ok := result["allOf"].([]interface{}); ok && len(parts) > 1 {
+			// merge composition, additionalProperties can't be used with allOf
+			properties := make(map[string]interface{})
+			var required []interface{}
+			for _, part := range parts {
+				part := part.(map[string]interface{})
+				if ref, ok := part["$ref"].(string); ok {
+					part = schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+				}
+				for k, v := range part["properties"].(map[string]interface{}) {
+					properties[k] = v
+				}
+				if list, ok := part["required"].([]interface{}); ok {
+					required = append(required, list...)
+				}
+			}
+			delete(result, "allOf")
+			result["type"] = "object"
+			result["properties"] = properties
+			result["required"] = required
+		}
+		if _, ok := result["properties"]; ok {
+			if _, ok := result["additionalProperties"]; !ok {
+				result["additionalProperties"] = false
+			}
+		}
+		if nullable, _ := result["nullable"].(bool); nullable {
+			delete(result, "nullable")
+			if kind, ok := result["type"]; ok {
+				result["type"] = []interface{}{kind, "null"}
+			} else {
+				result = map[string]interface{}{
+					"anyOf": []interface{}{result, map[string]interface{}{"type": "null"}},
+				}
+			}
+		}
+		for k, v := range result {
+			if k != "properties" {
+				result[k] = jsonSchema(v, schemas)
+			}
+		}
+		if properties, ok := result["properties"].(map[string]interface{}); ok {
+			converted := make(map[string]interface{})
+			for k, v := range properties {
+				converted[k] = jsonSchema(v, schemas)
+			}
+			result["properties"] = converted
+		}
+		return result
+	default:
+		return schema
+	}
+}
+
+func validateOpenAPI(t *testing.T, name string, value interface{}) {
+	doc := loadOpenAPI(t)
+	schemas := doc.Components.Schemas
+	definitions := make(map[string]interface{})
+	for k, v := range schemas {
+		definitions[k] = jsonSchema(v, schemas)
+	}
+	schema := map[string]interface{}{
+		"$ref":       "#/components/schemas/" + name,
+		"components": map[string]interface{}{"schemas": definitions},
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, resultErr := range result.Errors() {
+		t.Errorf("%s doesn't match specification: %s", name, resultErr)
+	}
+}
+
+func testServerScores() *rcon.ServerScores {
+	return &rcon.ServerScores{
+		Gametype:     "ctf",
+		Map:          "bloodrage",
+		GameTime:     600,
+		PlayerLabels: []string{"score", "caps"},
+		TeamLabels:   []string{"caps"},
+		TeamScores:   map[int][]int64{5: {3}, 14: {1}},
+		Players: []rcon.PlayerScores{
+			{PlayerId: 1, Name: "^1Red", Team: 5, PlayingTime: 300, Scores: []float64{10, 3}},
+		},
+	}
+}
+
+func TestOpenAPIServerAll(t *testing.T) {
+	status := &rcon.ServerStatus{
+		Public:        1,
+		Hostname:      "Regulars CTF",
+		Version:       "Xonotic build 0.8.5",
+		Protocol:      "3504",
+		Map:           "bloodrage",
+		PlayersActive: 2,
+		PlayersMax:    16,
+		Players: []rcon.Player{
+			{PL: 0, Ping: 50, Time: 120, Frags: 10, Number: 1, Name: "^1Red", Type: rcon.PlayerTypePlayer, Country: "NL"},
+			{Number: 2, Name: "[BOT]Bot", Type: rcon.PlayerTypeBot, IsBot: true},
+		},
+	}
+	info := &rcon.ServerInfo{
+		Gametype:     "ctf",
+		Version:      "0.8.5",
+		PlayerLabels: []rcon.ScoreLabel{{Name: "score", Primary: true}},
+		TeamScores:   []rcon.TeamScore{{Team: 5, Scores: []int64{3}}},
+	}
+	validateOpenAPI(t, "ServerAll", ServerAll{status, info, testServerScores()})
+	validateOpenAPI(t, "ServerAll", ServerAll{ServerStatus: &rcon.ServerStatus{}, Info: &rcon.ServerInfo{}})
+	validateOpenAPI(t, "ServerInfoV2", ServerInfoV2{info, []string{"instagib"}})
+}
+
+func TestOpenAPIServerScores(t *testing.T) {
+	validateOpenAPI(t, "ServerScores", testServerScores())
+	validateOpenAPI(t, "ServerScores", &rcon.ServerScores{})
+}
+
+func TestOpenAPIRecords(t *testing.T) {
+	entries, _ := filterRecords(testRecords(), &RecordsQuery{Sort: "map"}, map[string]time.Time{"bloodrage": time.Now()})
+	for _, format := range []string{"object", "array"} {
+		body, err := renderRecords(entries, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := "Records"
+		if format == "array" {
+			name = "RecordEntries"
+		}
+		validateOpenAPI(t, name, json.RawMessage(body))
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	rawPopulationRetention    = time.Hour * 24 * 7
+	hourlyPopulationRetention = time.Hour * 24 * 365
+	defaultPopulationRange    = time.Hour * 24
+	maxPopulationPoints       = 10000
+	defaultHeatmapWeeks       = 4
+)
+
+// PopulationPoint is players count sample or average of samples in time range
+type PopulationPoint struct {
+	Time       time.Time `json:"time"`
+	Active     float64   `json:"active"`
+	Spectators float64   `json:"spectators"`
+	Bots       float64   `json:"bots"`
+	// number of raw samples in averaged point
+	Samples int `json:"samples"`
+}
+
+func (p *PopulationPoint) Humans() float64 {
+	return p.Active - p.Bots + p.Spectators
+}
+
+type populationSeries struct {
+	Raw    []PopulationPoint `json:"raw"`
+	Hourly []PopulationPoint `json:"hourly"`
+}
+
+// PopulationRecorder keeps players count history of servers, raw samples
+// are kept for a week and hourly averages are kept for a year
+type PopulationRecorder struct {
+	path       func() string
+	lock       sync.Mutex
+	loaded     bool
+	loadedPath string
+	// broken file isn't read again until its path or modification time is changed
+	failedPath    string
+	failedModTime time.Time
+	series        map[string]*populationSeries
+}
+
+func NewPopulationRecorder(path func() string) *PopulationRecorder {
+	return &PopulationRecorder{
+		path:   path,
+		series: make(map[string]*populationSeries),
+	}
+}
+
+// load reads history when path is changed, lock should be held
+func (p *PopulationRecorder) load() {
+	series := make(map[string]*populationSeries)
+
+	path := p.path()
+	if p.loaded && path == p.loadedPath {
+		return
+	}
+	if path != "" {
+		modTime := fileModTime(path)
+		if path == p.failedPath && modTime.Equal(p.failedModTime) {
+			return
+		}
+		// history isn't saved until broken file is fixed
+		err := readJSONFile(path, &series)
+		if err != nil {
+			slog.Error("Can't load population history", "error", err)
+			p.failedPath = path
+			p.failedModTime = modTime
+			return
+		}
+	}
+	p.failedPath = ""
+	p.loaded = true
+	p.loadedPath = path
+	// samples collected before path was configured are kept
+	for name, s := range series {
+		if _, ok := p.series[name]; !ok {
+			p.series[name] = s
+		}
+	}
+}
+
+// save writes history to disk, lock should be held
+func (p *PopulationRecorder) save() error {
+	if p.loadedPath == "" {
+		return nil
+	}
+	return writeJSONFile(p.loadedPath, p.series)
+}
+
+func (p *PopulationRecorder) Save() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.save()
+}
+
+func averagePoints(t time.Time, points []PopulationPoint) PopulationPoint {
+	result := PopulationPoint{Time: t}
+	for _, point := range points {
+		weight := float64(point.Samples)
+		result.Active += point.Active * weight
+		result.Spectators += point.Spectators * weight
+		result.Bots += point.Bots * weight
+		result.Samples += point.Samples
+	}
+	if result.Samples > 0 {
+		result.Active /= float64(result.Samples)
+		result.Spectators /= float64(result.Samples)
+		result.Bots /= float64(result.Samples)
+	}
+	return result
+}
+
+// rollup averages finished hours of raw samples and drops expired data,
+// it returns true when new hourly points were added
+func (s *populationSeries) rollup(now time.Time) bool {
+	var last time.Time
+
+	added := false
+	currentHour := now.Truncate(time.Hour)
+	if len(s.Hourly) > 0 {
+		last = s.Hourly[len(s.Hourly)-1].Time
+	}
+	start := 0
+	for start < len(s.Raw) {
+		hour := s.Raw[start].Time.Truncate(time.Hour)
+		end := start
+		for end < len(s.Raw) && s.Raw[end].Time.Truncate(time.Hour).Equal(hour) {
+			end++
+		}
+		if !hour.Before(currentHour) {
+			break
+		}
+		if hour.After(last) {
+			s.Hourly = append(s.Hourly, averagePoints(hour, s.Raw[start:end]))
+			added = true
+		}
+		start = end
+	}
+	s.Raw = dropBefore(s.Raw, now.Add(-rawPopulationRetention))
+	s.Hourly = dropBefore(s.Hourly, now.Add(-hourlyPopulationRetention))
+	return added
+}
+
+func dropBefore(points []PopulationPoint, t time.Time) []PopulationPoint {
+	i := 0
+	for i < len(points) && points[i].Time.Before(t) {
+		i++
+	}
+	if i == 0 {
+		return points
+	}
+	return append([]PopulationPoint(nil), points[i:]...)
+}
+
+// Record stores players count sample of server
+func (p *PopulationRecorder) Record(server string, t time.Time, stats rcon.PlayerStats) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.load()
+	s, ok := p.series[server]
+	if !ok {
+		s = new(populationSeries)
+		p.series[server] = s
+	}
+	s.Raw = append(s.Raw, PopulationPoint{
+		Time:       t.UTC(),
+		Active:     float64(stats.Active),
+		Spectators: float64(stats.Spectators),
+		Bots:       float64(stats.Bots),
+		Samples:    1,
+	})
+	if s.rollup(t) {
+		if err := p.save(); err != nil {
+			slog.Error("Can't save population history", "error", err)
+		}
+	}
+}
+
+// Query returns points averaged by step, raw samples are used only
+// when step is less than hour and range is within raw retention
+func (p *PopulationRecorder) Query(server string, from, to time.Time, step time.Duration) []PopulationPoint {
+	var source []PopulationPoint
+
+	result := []PopulationPoint{}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.load()
+	s, ok := p.series[server]
+	if !ok {
+		return result
+	}
+	if step < time.Hour && !from.Before(time.Now().Add(-rawPopulationRetention)) {
+		source = s.Raw
+	} else {
+		source = s.Hourly
+	}
+	var bucket []PopulationPoint
+	var bucketStart time.Time
+	for _, point := range source {
+		if point.Time.Before(from) || !point.Time.Before(to) {
+			continue
+		}
+		start := from.Add(point.Time.Sub(from) / step * step)
+		if len(bucket) > 0 && !start.Equal(bucketStart) {
+			result = append(result, averagePoints(bucketStart, bucket))
+			bucket = bucket[:0]
+		}
+		bucketStart = start
+		bucket = append(bucket, point)
+	}
+	if len(bucket) > 0 {
+		result = append(result, averagePoints(bucketStart, bucket))
+	}
+	return result
+}
+
+// Heatmap returns average number of human players by weekday and hour
+func (p *PopulationRecorder) Heatmap(server string, from time.Time, loc *time.Location) [7][24]float64 {
+	var sums, counts [7][24]float64
+	var result [7][24]float64
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.load()
+	s, ok := p.series[server]
+	if !ok {
+		return result
+	}
+	for _, point := range s.Hourly {
+		if point.Time.Before(from) {
+			continue
+		}
+		t := point.Time.In(loc)
+		sums[t.Weekday()][t.Hour()] += point.Humans()
+		counts[t.Weekday()][t.Hour()]++
+	}
+	for day := range result {
+		for hour := range result[day] {
+			if counts[day][hour] > 0 {
+				result[day][hour] = sums[day][hour] / counts[day][hour]
+			}
+		}
+	}
+	return result
+}
+
+func (p *PopulationRecorder) sample() {
+	var wg sync.WaitGroup
+
+	for name, serverConf := range getConfig().Servers {
+		wg.Add(1)
+		go func(name string, serverConf rcon.ServerConfig) {
+			defer wg.Done()
+			status, err := rcon.QueryWithRetries(time.Millisecond*1000, 3,
+				func(deadline time.Time) (*rcon.ServerStatus, error) {
+					return rcon.QueryRconStatus(&serverConf, deadline)
+				})
+			if err != nil {
+				return
+			}
+			p.Record(name, time.Now(), rcon.CountPlayers(status))
+		}(name, serverConf)
+	}
+	wg.Wait()
+}
+
+// Run samples players count of all servers
+func (p *PopulationRecorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.sample()
+		}
+	}
+}
+
+// parseTimeParam parses unix timestamp or RFC3339 time
+func parseTimeParam(value string, defaultValue time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(ts, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func population(w http.ResponseWriter, r *http.Request) {
+	serverName := chi.URLParam(r, "server")
+	if _, ok := getConfig().PublicServer(serverName); !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	to, err := parseTimeParam(query.Get("to"), time.Now().UTC())
+	if err != nil {
+		http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), to.Add(-defaultPopulationRange))
+	if err != nil {
+		http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from should be before to", http.StatusBadRequest)
+		return
+	}
+	step := time.Minute
+	if from.Before(time.Now().Add(-rawPopulationRetention)) {
+		step = time.Hour
+	}
+	if value := query.Get("step"); value != "" {
+		step, err = time.ParseDuration(value)
+		if err != nil || step < time.Minute {
+			http.Error(w, "step should be duration of at least 1m", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Sub(from)/step > maxPopulationPoints {
+		http.Error(w, fmt.Sprintf("Too many points, max is %d", maxPopulationPoints), http.StatusBadRequest)
+		return
+	}
+	points := populationRecorder.Query(serverName, from, to, step)
+	if query.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"time", "active", "spectators", "bots", "samples"})
+		for _, point := range points {
+			writer.Write([]string{
+				point.Time.Format(time.RFC3339),
+				strconv.FormatFloat(point.Active, 'f', -1, 64),
+				strconv.FormatFloat(point.Spectators, 'f', -1, 64),
+				strconv.FormatFloat(point.Bots, 'f', -1, 64),
+				strconv.Itoa(point.Samples),
+			})
+		}
+		writer.Flush()
+		return
+	}
+	writeJSON(w, struct {
+		Server string            `json:"server"`
+		From   time.Time         `json:"from"`
+		To     time.Time         `json:"to"`
+		Step   int64             `json:"step"`
+		Points []PopulationPoint `json:"points"`
+	}{serverName, from, to, int64(step / time.Second), points})
+}
+
+func populationHeatmap(w http.ResponseWriter, r *http.Request) {
+	serverName := chi.URLParam(r, "server")
+	if _, ok := getConfig().PublicServer(serverName); !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	loc := time.UTC
+	if tz := query.Get("tz"); tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			http.Error(w, "Unknown timezone", http.StatusBadRequest)
+			return
+		}
+	}
+	weeks := defaultHeatmapWeeks
+	if value := query.Get("weeks"); value != "" {
+		var err error
+		weeks, err = strconv.Atoi(value)
+		if err != nil || weeks < 1 || weeks > 52 {
+			http.Error(w, "weeks should be from 1 to 52", http.StatusBadRequest)
+			return
+		}
+	}
+	from := time.Now().Add(-time.Hour * 24 * 7 * time.Duration(weeks))
+	writeJSON(w, struct {
+		Server   string         `json:"server"`
+		Timezone string         `json:"timezone"`
+		Weeks    int            `json:"weeks"`
+		Hours    [7][24]float64 `json:"hours"`
+	}{serverName, loc.String(), weeks, populationRecorder.Heatmap(serverName, from, loc)})
+}
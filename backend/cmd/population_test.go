@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+func TestPopulationRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "population.json")
+	recorder := NewPopulationRecorder(func() string { return path })
+	start := time.Now().UTC().Truncate(time.Hour).Add(-time.Hour * 3)
+	for i := 0; i < 180; i++ {
+		// 2 players during first hour, 4 players after it
+		stats := rcon.PlayerStats{Active: 3, Bots: 1, Spectators: 0}
+		if i >= 60 {
+			stats = rcon.PlayerStats{Active: 4, Bots: 0, Spectators: 1}
+		}
+		recorder.Record("pub", start.Add(time.Duration(i)*time.Minute), stats)
+	}
+	points := recorder.Query("pub", start, start.Add(time.Hour*3), time.Minute*30)
+	if len(points) != 6 {
+		t.Fatal("Incorrect number of points ", points)
+	}
+	if points[0].Active != 3 || points[0].Samples != 30 || !points[0].Time.Equal(start) {
+		t.Error("Incorrect first point ", points[0])
+	}
+	// hourly rollups are created only for finished hours
+	hourly := recorder.Query("pub", start, start.Add(time.Hour*3), time.Hour)
+	if len(hourly) != 2 || hourly[0].Bots != 1 || hourly[1].Spectators != 1 {
+		t.Fatal("Incorrect hourly points ", hourly)
+	}
+	heatmap := recorder.Heatmap("pub", start, time.UTC)
+	if heatmap[start.Weekday()][start.Hour()] != 2 {
+		t.Error("Incorrect heatmap value ", heatmap[start.Weekday()][start.Hour()])
+	}
+
+	// history is restored from disk
+	recorder = NewPopulationRecorder(func() string { return path })
+	hourly = recorder.Query("pub", start, start.Add(time.Hour*3), time.Hour)
+	if len(hourly) != 2 {
+		t.Error("History wasn't restored ", hourly)
+	}
+}
+
+func TestPopulationRetention(t *testing.T) {
+	recorder := NewPopulationRecorder(func() string { return "" })
+	now := time.Now().UTC()
+	recorder.Record("pub", now.Add(-rawPopulationRetention-time.Hour), rcon.PlayerStats{Active: 1})
+	recorder.Record("pub", now, rcon.PlayerStats{Active: 2})
+	series := recorder.series["pub"]
+	if len(series.Raw) != 1 || len(series.Hourly) != 1 {
+		t.Error("Expired raw samples should be rolled up and dropped ", series)
+	}
+}
+
+func TestPopulationBrokenFile(t *testing.T) {
+	var buf bytes.Buffer
+
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	path := filepath.Join(t.TempDir(), "population.json")
+	os.WriteFile(path, []byte("{"), 0600)
+	recorder := NewPopulationRecorder(func() string { return path })
+	for i := 0; i < 3; i++ {
+		recorder.Query("pub", time.Now().Add(-time.Hour), time.Now(), time.Minute)
+	}
+	if count := strings.Count(buf.String(), "Can't load population history"); count != 1 {
+		t.Error("Broken file should be reported once ", count)
+	}
+
+	// fixed file is loaded
+	os.WriteFile(path, []byte("{}"), 0600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	recorder.Record("pub", time.Now(), rcon.PlayerStats{Active: 1})
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "pub") {
+		t.Error("History should be saved after file is fixed ", string(data))
+	}
+}
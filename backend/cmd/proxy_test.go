@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "192.0.2.1"}
+	cases := []struct {
+		remote    string
+		forwarded string
+		realIP    string
+		expected  string
+	}{
+		// direct connections can't set client address
+		{"198.51.100.1:4000", "203.0.113.1", "", "198.51.100.1"},
+		{"192.0.2.1:4000", "203.0.113.1", "", "203.0.113.1"},
+		// spoofed left entries are ignored
+		{"10.0.0.1:4000", "1.2.3.4, 203.0.113.1, 10.0.0.2", "", "203.0.113.1"},
+		{"10.0.0.1:4000", "", "203.0.113.2", "203.0.113.2"},
+		{"10.0.0.1:4000", "", "", "10.0.0.1"},
+		// unix socket is served only to local proxy
+		{"@", "203.0.113.3", "", "203.0.113.3"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if c.realIP != "" {
+			req.Header.Set("X-Real-IP", c.realIP)
+		}
+		if ip := clientIP(req, proxies); ip != c.expected {
+			t.Errorf("%s %q: expected %s, got %s", c.remote, c.forwarded, c.expected, ip)
+		}
+	}
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Invalid network should be rejected")
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+const maxRecordsCacheEntries = 256
+
+// RecordEntry is item of /records?format=array response
+type RecordEntry struct {
+	Map   string  `json:"map"`
+	Name  string  `json:"name"`
+	Value float64 `json:"val"`
+	// time when record was set, it's known only for records from records feed
+	Date *time.Time `json:"date,omitempty"`
+}
+
+type RecordsQuery struct {
+	Gametype string
+	Map      string
+	Holder   string
+	Sort     string
+	Format   string
+	Limit    int
+	Offset   int
+}
+
+type cachedRecords struct {
+	stamp string
+	etag  string
+	total int
+	body  []byte
+}
+
+// RecordsCache keeps rendered responses by query, entries are invalidated
+// when gamedbs, maps or records feed are changed
+type RecordsCache struct {
+	lock    sync.Mutex
+	entries map[string]*cachedRecords
+}
+
+func (c *RecordsCache) Get(key, stamp string) *cachedRecords {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.stamp != stamp {
+		return nil
+	}
+	return entry
+}
+
+func (c *RecordsCache) Store(key string, entry *cachedRecords) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.entries == nil || len(c.entries) >= maxRecordsCacheEntries {
+		c.entries = make(map[string]*cachedRecords)
+	}
+	c.entries[key] = entry
+}
+
+var recordsCache RecordsCache
+
+func parseRecordsQuery(values url.Values) (*RecordsQuery, error) {
+	var err error
+
+	query := &RecordsQuery{
+		Gametype: values.Get("gametype"),
+		Map:      values.Get("map"),
+		Holder:   strings.ToLower(values.Get("holder")),
+		Sort:     values.Get("sort"),
+		Format:   values.Get("format"),
+	}
+	if query.Gametype == "" {
+		query.Gametype = "ctf"
+	}
+	if _, ok := gametypeRecordKeys[query.Gametype]; !ok {
+		return nil, fmt.Errorf("Unknown gametype %q", query.Gametype)
+	}
+	switch query.Sort {
+	case "":
+		query.Sort = "map"
+	case "map", "time", "date":
+	default:
+		return nil, fmt.Errorf("Unknown sort %q", query.Sort)
+	}
+	switch query.Format {
+	case "", "object", "array":
+	default:
+		return nil, fmt.Errorf("Unknown format %q", query.Format)
+	}
+	if value := values.Get("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit < 0 {
+			return nil, fmt.Errorf("Invalid limit %q", value)
+		}
+	}
+	if value := values.Get("offset"); value != "" {
+		query.Offset, err = strconv.Atoi(value)
+		if err != nil || query.Offset < 0 {
+			return nil, fmt.Errorf("Invalid offset %q", value)
+		}
+	}
+	return query, nil
+}
+
+// Key is canonical form of query for cache
+func (q *RecordsQuery) Key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%d|%d",
+		q.Gametype, q.Map, q.Holder, q.Sort, q.Format, q.Limit, q.Offset)
+}
+
+// recordsStamp identifies state of data used for records response
+func recordsStamp(gameDB []string, mapsSet map[string]bool, events []RecordEvent) string {
+	var maps []string
+
+	hash := md5.New()
+	for _, path := range gameDB {
+		info, err := os.Stat(path)
+		if err == nil {
+			fmt.Fprintf(hash, "%s:%d:%d\n", path, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(hash, "%s:missing\n", path)
+		}
+	}
+	for mapname := range mapsSet {
+		maps = append(maps, mapname)
+	}
+	sort.Strings(maps)
+	fmt.Fprintln(hash, strings.Join(maps, " "))
+	if len(events) > 0 {
+		fmt.Fprintln(hash, events[0].ID)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// recordDates finds when current records were set from records feed
+func recordDates(events []RecordEvent) map[string]time.Time {
+	dates := make(map[string]time.Time)
+	// events are sorted from newest
+	for _, event := range events {
+		if _, ok := dates[event.Map]; !ok {
+			dates[event.Map] = event.Time
+		}
+	}
+	return dates
+}
+
+// filterRecords applies query to records, it returns page of sorted entries and total count
+func filterRecords(records Records, query *RecordsQuery, dates map[string]time.Time) ([]RecordEntry, int) {
+	entries := []RecordEntry{}
+	for mapname, item := range records {
+		if !strings.HasPrefix(mapname, query.Map) {
+			continue
+		}
+		if query.Holder != "" && !strings.Contains(strings.ToLower(rcon.StripColors(item.Name)), query.Holder) {
+			continue
+		}
+		entry := RecordEntry{Map: mapname, Name: item.Name, Value: item.Value}
+		if date, ok := dates[mapname]; ok && query.Gametype == "ctf" {
+			entry.Date = &date
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch query.Sort {
+		case "time":
+			if a.Value != b.Value {
+				return a.Value < b.Value
+			}
+		case "date":
+			// newest first, records without date are last
+			if (a.Date == nil) != (b.Date == nil) {
+				return a.Date != nil
+			}
+			if a.Date != nil && !a.Date.Equal(*b.Date) {
+				return a.Date.After(*b.Date)
+			}
+		}
+		return a.Map < b.Map
+	})
+	total := len(entries)
+	if query.Offset >= len(entries) {
+		entries = entries[:0]
+	} else {
+		entries = entries[query.Offset:]
+	}
+	if query.Limit > 0 && query.Limit < len(entries) {
+		entries = entries[:query.Limit]
+	}
+	return entries, total
+}
+
+func renderRecords(entries []RecordEntry, format string) ([]byte, error) {
+	if format == "array" {
+		return json.Marshal(entries)
+	}
+	records := make(Records)
+	for _, entry := range entries {
+		records[entry.Map] = &RecordItem{Name: entry.Name, Value: entry.Value}
+	}
+	return json.Marshal(records)
+}
+
+func records(w http.ResponseWriter, r *http.Request) {
+	query, err := parseRecordsQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conf := getConfig()
+	mapsSet := mapsState.GetMapsSet()
+	events := recordFeed.Events()
+	stamp := recordsStamp(conf.GameDB, mapsSet, events)
+	cached := recordsCache.Get(query.Key(), stamp)
+	if cached == nil {
+		filter := func(key, value string) bool {
+			i := strings.Index(key, "/")
+			if i >= 0 {
+				mapname := key[:i]
+				if _, ok := mapsSet[mapname]; ok {
+					return true
+				} else {
+					return false
+				}
+			} else {
+				return false
+			}
+		}
+		records, err := ReadRecordsWithFilter(conf.GameDB, query.Gametype, filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		entries, total := filterRecords(records, query, recordDates(events))
+		body, err := renderRecords(entries, query.Format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cached = &cachedRecords{stamp: stamp, etag: generateEtag(body), total: total, body: body}
+		recordsCache.Store(query.Key(), cached)
+	}
+	w.Header().Set("Etag", cached.etag)
+	w.Header().Set("X-Total-Count", strconv.Itoa(cached.total))
+	if r.Header.Get("If-None-Match") == cached.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(cached.body)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+const (
+	maxFeedEvents = 100
+	feedTitle     = "Captime records"
+	feedAuthor    = "TheRegulars"
+	// feedURN identifies feed and its entries, it doesn't depend on host or path of request,
+	// so readers don't see same event twice
+	feedURN       = "urn:theregulars:records"
+	atomNamespace = "http://www.w3.org/2005/Atom"
+)
+
+type RecordsFeedConfig struct {
+	// file where feed and last seen records are stored, feed is kept only in memory without it
+	Store string `json:"store,omitempty" yaml:"store,omitempty"`
+	// webhooks receive record broken events
+	Webhooks []string `json:"webhooks,omitempty" yaml:"webhooks,omitempty"`
+}
+
+// RecordEvent is emitted when captime record on map is broken
+type RecordEvent struct {
+	ID          string    `json:"id"`
+	Map         string    `json:"map"`
+	OldHolder   string    `json:"old_holder"`
+	NewHolder   string    `json:"new_holder"`
+	OldTime     float64   `json:"old_time"`
+	NewTime     float64   `json:"new_time"`
+	Improvement float64   `json:"improvement"`
+	Time        time.Time `json:"time"`
+}
+
+func (e *RecordEvent) Title() string {
+	return fmt.Sprintf("%s broke record on %s", rcon.StripColors(e.NewHolder), e.Map)
+}
+
+// URN is stable id of event in atom and rss feeds
+func (e *RecordEvent) URN() string {
+	return feedURN + ":" + e.ID
+}
+
+// Content is preformatted message for discord-like webhooks
+func (e *RecordEvent) Content() string {
+	return fmt.Sprintf("%s set new record on %s: %.2fs (-%.2fs), previous record %.2fs by %s",
+		rcon.StripColors(e.NewHolder), e.Map, e.NewTime, e.Improvement,
+		e.OldTime, rcon.StripColors(e.OldHolder))
+}
+
+type recordsFeedState struct {
+	Records Records       `json:"records"`
+	Events  []RecordEvent `json:"events"`
+}
+
+type RecordsFeed struct {
+	config     func() *RecordsFeedConfig
+	client     *http.Client
+	lock       sync.Mutex
+	loaded     bool
+	loadedPath string
+	// broken file isn't read again until its path or modification time is changed
+	failedPath    string
+	failedModTime time.Time
+	state         recordsFeedState
+}
+
+func NewRecordsFeed(config func() *RecordsFeedConfig) *RecordsFeed {
+	return &RecordsFeed{
+		config: config,
+		client: &http.Client{Timeout: time.Second * 5},
+		state:  recordsFeedState{Events: []RecordEvent{}},
+	}
+}
+
+func (f *RecordsFeed) storePath() string {
+	if conf := f.config(); conf != nil {
+		return conf.Store
+	}
+	return ""
+}
+
+// load reads feed when path is changed, lock should be held
+func (f *RecordsFeed) load() {
+	state := recordsFeedState{Events: []RecordEvent{}}
+
+	path := f.storePath()
+	if f.loaded && path == f.loadedPath {
+		return
+	}
+	if path != "" {
+		modTime := fileModTime(path)
+		if path == f.failedPath && modTime.Equal(f.failedModTime) {
+			return
+		}
+		// feed isn't saved until broken file is fixed
+		err := readJSONFile(path, &state)
+		if err != nil {
+			slog.Error("Can't load records feed", "error", err)
+			f.failedPath = path
+			f.failedModTime = modTime
+			return
+		}
+	}
+	f.failedPath = ""
+	f.loaded = true
+	f.loadedPath = path
+	if state.Records != nil {
+		f.state = state
+	}
+}
+
+// recordsEqual reports whether both records have same holders and times
+func recordsEqual(a, b Records) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for mapname, item := range a {
+		other, ok := b[mapname]
+		if !ok || (item == nil) != (other == nil) || (item != nil && *item != *other) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectRecordChanges compares successive records and returns events for
+// maps where record time was improved, new maps don't produce events
+func detectRecordChanges(oldRecords, newRecords Records, now time.Time) []RecordEvent {
+	var events []RecordEvent
+
+	for mapname, item := range newRecords {
+		old, ok := oldRecords[mapname]
+		if !ok || old.Value <= 0 || item.Value <= 0 || item.Value >= old.Value {
+			continue
+		}
+		events = append(events, RecordEvent{
+			ID:          fmt.Sprintf("%s-%d", mapname, now.UnixNano()),
+			Map:         mapname,
+			OldHolder:   old.Name,
+			NewHolder:   item.Name,
+			OldTime:     old.Value,
+			NewTime:     item.Value,
+			Improvement: old.Value - item.Value,
+			Time:        now,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Map < events[j].Map
+	})
+	return events
+}
+
+// Update compares records with last seen records and stores new events,
+// first update only remembers records
+func (f *RecordsFeed) Update(records Records, now time.Time) []RecordEvent {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.load()
+	var events []RecordEvent
+	if f.state.Records != nil {
+		events = detectRecordChanges(f.state.Records, records, now.UTC())
+	}
+	changed := f.state.Records == nil || !recordsEqual(f.state.Records, records)
+	f.state.Records = records
+	// newest events first
+	for _, event := range events {
+		f.state.Events = append([]RecordEvent{event}, f.state.Events...)
+	}
+	if len(f.state.Events) > maxFeedEvents {
+		f.state.Events = f.state.Events[:maxFeedEvents]
+	}
+	// store is written only when something is changed, not on every poll
+	if f.loadedPath != "" && (changed || len(events) > 0) {
+		if err := writeJSONFile(f.loadedPath, f.state); err != nil {
+			slog.Error("Can't save records feed", "error", err)
+		}
+	}
+	return events
+}
+
+func (f *RecordsFeed) Events() []RecordEvent {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.load()
+	result := make([]RecordEvent, len(f.state.Events))
+	copy(result, f.state.Events)
+	return result
+}
+
+func (f *RecordsFeed) Post(webhook string, event RecordEvent) error {
+	data, err := json.Marshal(struct {
+		RecordEvent
+		Content         string          `json:"content"`
+		AllowedMentions allowedMentions `json:"allowed_mentions"`
+	}{event, event.Content(), noMentions})
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Post(webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Run periodically reads gamedb and announces broken records
+func (f *RecordsFeed) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			records, err := ReadCaptimeRecordsWithFilter(getConfig().GameDB,
+				func(key, value string) bool { return true })
+			if err != nil {
+				slog.Error("Can't read records", "error", err)
+				continue
+			}
+			events := f.Update(records, time.Now())
+			conf := f.config()
+			if conf == nil {
+				continue
+			}
+			for _, event := range events {
+				for _, webhook := range conf.Webhooks {
+					if err := f.Post(webhook, event); err != nil {
+						slog.Warn("Can't post record event to webhook", "error", err)
+					}
+				}
+			}
+		}
+	}
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Summary string `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+func feedURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+func writeXML(w http.ResponseWriter, contentType string, value interface{}) {
+	data, err := xml.MarshalIndent(value, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// recordsFeed serves broken records as json, atom or rss
+func recordsFeed(w http.ResponseWriter, r *http.Request) {
+	events := recordFeed.Events()
+	link := feedURL(r)
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, struct {
+			Events []RecordEvent `json:"events"`
+		}{events})
+	case "atom":
+		feed := atomFeed{
+			Xmlns:   atomNamespace,
+			ID:      feedURN,
+			Title:   feedTitle,
+			Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: feedAuthor},
+			Link:    atomLink{Href: link + "?format=atom", Rel: "self"},
+		}
+		if len(events) > 0 {
+			feed.Updated = events[0].Time.Format(time.RFC3339)
+		}
+		for _, event := range events {
+			feed.Entries = append(feed.Entries, atomEntry{
+				ID:      event.URN(),
+				Title:   event.Title(),
+				Updated: event.Time.Format(time.RFC3339),
+				Summary: event.Content(),
+			})
+		}
+		writeXML(w, "application/atom+xml", feed)
+	case "rss":
+		var feed rssFeed
+		feed.Version = "2.0"
+		feed.Channel.Title = feedTitle
+		feed.Channel.Link = link
+		feed.Channel.Description = "Broken captime records"
+		for _, event := range events {
+			feed.Channel.Items = append(feed.Channel.Items, rssItem{
+				Title:       event.Title(),
+				GUID:        rssGUID{Value: event.URN()},
+				PubDate:     event.Time.Format(time.RFC1123Z),
+				Description: event.Content(),
+			})
+		}
+		writeXML(w, "application/rss+xml", feed)
+	default:
+		http.Error(w, "Unknown feed format", http.StatusBadRequest)
+	}
+}
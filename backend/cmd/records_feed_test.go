@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectRecordChanges(t *testing.T) {
+	oldRecords := Records{
+		"afterslime": {Name: "^1Old", Value: 25.5},
+		"bloodrage":  {Name: "Keeper", Value: 40},
+	}
+	newRecords := Records{
+		"afterslime": {Name: "^2New", Value: 24.25},
+		"bloodrage":  {Name: "Keeper", Value: 40},
+		"catharsis":  {Name: "First", Value: 30},
+	}
+	events := detectRecordChanges(oldRecords, newRecords, time.Unix(1000, 0))
+	if len(events) != 1 {
+		t.Fatal("Incorrect number of events ", events)
+	}
+	event := events[0]
+	if event.Map != "afterslime" || event.OldHolder != "^1Old" || event.NewHolder != "^2New" ||
+		event.OldTime != 25.5 || event.NewTime != 24.25 || event.Improvement != 1.25 {
+		t.Error("Incorrect event ", event)
+	}
+	if content := event.Content(); !strings.Contains(content, "New set new record on afterslime: 24.25s (-1.25s)") {
+		t.Error("Incorrect event content ", content)
+	}
+}
+
+func TestRecordsFeedUpdate(t *testing.T) {
+	conf := &RecordsFeedConfig{Store: filepath.Join(t.TempDir(), "feed.json")}
+	feed := NewRecordsFeed(func() *RecordsFeedConfig { return conf })
+	if events := feed.Update(Records{"afterslime": {Name: "Old", Value: 25}}, time.Now()); len(events) != 0 {
+		t.Error("First update shouldn't produce events ", events)
+	}
+	if events := feed.Update(Records{"afterslime": {Name: "New", Value: 20}}, time.Now()); len(events) != 1 {
+		t.Error("Broken record wasn't detected ", events)
+	}
+	// feed is restored from disk
+	feed = NewRecordsFeed(func() *RecordsFeedConfig { return conf })
+	if events := feed.Update(Records{"afterslime": {Name: "New", Value: 20}}, time.Now()); len(events) != 0 {
+		t.Error("Unchanged records shouldn't produce events ", events)
+	}
+	if events := feed.Events(); len(events) != 1 || events[0].NewHolder != "New" {
+		t.Error("Incorrect feed events ", events)
+	}
+}
+
+func TestRecordsFeedUnchanged(t *testing.T) {
+	conf := &RecordsFeedConfig{Store: filepath.Join(t.TempDir(), "feed.json")}
+	feed := NewRecordsFeed(func() *RecordsFeedConfig { return conf })
+	feed.Update(Records{"afterslime": {Name: "Old", Value: 25}}, time.Now())
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(conf.Store, past, past)
+
+	// polling same records doesn't write store
+	feed.Update(Records{"afterslime": {Name: "Old", Value: 25}}, time.Now())
+	if !fileModTime(conf.Store).Equal(past) {
+		t.Error("Store shouldn't be written without changes")
+	}
+	feed.Update(Records{"afterslime": {Name: "Old", Value: 25}, "bloodrage": {Name: "New", Value: 40}}, time.Now())
+	if fileModTime(conf.Store).Equal(past) {
+		t.Error("Store should be written when records are changed")
+	}
+}
+
+func TestRecordsFeedAtom(t *testing.T) {
+	var feed atomFeed
+
+	recordFeed = NewRecordsFeed(func() *RecordsFeedConfig { return nil })
+	recordFeed.Update(Records{"afterslime": {Name: "Old", Value: 25}}, time.Now())
+	recordFeed.Update(Records{"afterslime": {Name: "New", Value: 20}}, time.Now())
+	w := httptest.NewRecorder()
+	recordsFeed(w, httptest.NewRequest("GET", "/records/feed?format=atom", nil))
+	if w.Header().Get("Content-Type") != "application/atom+xml" {
+		t.Error("Incorrect content type ", w.Header().Get("Content-Type"))
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal("Invalid atom feed ", err)
+	}
+	if len(feed.Entries) != 1 || feed.Entries[0].Title != "New broke record on afterslime" {
+		t.Error("Incorrect atom entries ", feed.Entries)
+	}
+	if feed.Author.Name != feedAuthor {
+		t.Error("Atom feed should have author ", feed.Author)
+	}
+}
+
+func TestRecordsFeedStableIDs(t *testing.T) {
+	recordFeed = NewRecordsFeed(func() *RecordsFeedConfig { return nil })
+	recordFeed.Update(Records{"afterslime": {Name: "Old", Value: 25}}, time.Now())
+	recordFeed.Update(Records{"afterslime": {Name: "New", Value: 20}}, time.Now())
+	ids := func(target string) (string, string) {
+		var atom atomFeed
+		var rss rssFeed
+
+		w := httptest.NewRecorder()
+		recordsFeed(w, httptest.NewRequest("GET", target+"?format=atom", nil))
+		xml.Unmarshal(w.Body.Bytes(), &atom)
+		w = httptest.NewRecorder()
+		recordsFeed(w, httptest.NewRequest("GET", target+"?format=rss", nil))
+		xml.Unmarshal(w.Body.Bytes(), &rss)
+		if len(atom.Entries) != 1 || len(rss.Channel.Items) != 1 {
+			t.Fatal("Incorrect feed entries ", atom.Entries, rss.Channel.Items)
+		}
+		return atom.Entries[0].ID, rss.Channel.Items[0].GUID.Value
+	}
+	atomID, guid := ids("http://example.com/records/feed")
+	otherAtomID, otherGUID := ids("http://mirror.example.org/api/v1/records/feed")
+	if atomID != otherAtomID || guid != otherGUID || !strings.HasPrefix(atomID, feedURN+":afterslime-") {
+		t.Error("Entry ids shouldn't depend on request ", atomID, otherAtomID, guid, otherGUID)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRecords() Records {
+	return Records{
+		"afterslime": {Name: "^1Red^7Player", Value: 20},
+		"bloodrage":  {Name: "Blue", Value: 10},
+		"bluemoon":   {Name: "redneck", Value: 30},
+		"catharsis":  {Name: "Green", Value: 15},
+	}
+}
+
+func mapsOf(entries []RecordEntry) []string {
+	var maps []string
+	for _, entry := range entries {
+		maps = append(maps, entry.Map)
+	}
+	return maps
+}
+
+func TestFilterRecords(t *testing.T) {
+	tests := []struct {
+		query string
+		maps  string
+		total int
+	}{
+		{"", "[afterslime bloodrage bluemoon catharsis]", 4},
+		{"sort=time", "[bloodrage catharsis afterslime bluemoon]", 4},
+		{"map=bl", "[bloodrage bluemoon]", 2},
+		{"holder=RED", "[afterslime bluemoon]", 2},
+		{"holder=redplayer", "[afterslime]", 1},
+		{"sort=time&offset=1&limit=2", "[catharsis afterslime]", 4},
+		{"offset=10", "[]", 4},
+		{"sort=date", "[catharsis bluemoon afterslime bloodrage]", 4},
+	}
+	now := time.Now()
+	dates := map[string]time.Time{
+		"catharsis": now,
+		"bluemoon":  now.Add(-time.Hour),
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		query, err := parseRecordsQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, total := filterRecords(testRecords(), query, dates)
+		if maps := fmt.Sprint(mapsOf(entries)); total != test.total || maps != test.maps {
+			t.Errorf("Query %q: incorrect result %v %d", test.query, maps, total)
+		}
+	}
+}
+
+func TestParseRecordsQueryErrors(t *testing.T) {
+	for _, query := range []string{"gametype=dm", "sort=name", "limit=-1", "offset=x", "format=xml"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseRecordsQuery(values); err == nil {
+			t.Errorf("Query %q should be invalid", query)
+		}
+	}
+}
+
+func TestReadCTSRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.db")
+	data := "8192\n\\afterslime/cts100record/time1\\1234\\afterslime/cts100record/netname1\\Fast\n" +
+		"\\afterslime/cts100record/time10\\9999\\afterslime/cts100record/netname10\\Slow\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadRecordsWithFilter([]string{path}, "cts", func(key, value string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records["afterslime"].Name != "Fast" || records["afterslime"].Value != 12.34 {
+		t.Error("Incorrect cts records ", records["afterslime"])
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maplistCvar        = "g_maplist"
+	maplistShuffleCvar = "g_maplist_shuffle"
+	maplistVotableCvar = "g_maplist_votable"
+)
+
+var mapnameRe *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+type RotationEntry struct {
+	Map       string `json:"map"`
+	Available bool   `json:"available"`
+	HasRecord bool   `json:"has_record"`
+	Current   bool   `json:"current"`
+}
+
+// Rotation is map list of server, it's built from cvars only, so state of running
+// map vote isn't known
+type Rotation struct {
+	Current string `json:"current"`
+	Next    string `json:"next,omitempty"`
+	Shuffle bool   `json:"shuffle"`
+	// Votable is number of maps offered in end of match vote
+	Votable int64           `json:"votable"`
+	Maps    []RotationEntry `json:"maps"`
+}
+
+type RotationUpdate struct {
+	Maps    []string `json:"maps"`
+	Shuffle *bool    `json:"shuffle,omitempty"`
+	Votable *int64   `json:"votable,omitempty"`
+}
+
+// RotationRecordsCache keeps records of gamedb for rotation, gamedb is read again only
+// when its files are changed
+type RotationRecordsCache struct {
+	lock    sync.Mutex
+	stamp   string
+	records Records
+}
+
+var rotationRecords RotationRecordsCache
+
+func (c *RotationRecordsCache) Get(gameDB []string) (Records, error) {
+	stamp := recordsStamp(gameDB, nil, nil)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.records != nil && c.stamp == stamp {
+		return c.records, nil
+	}
+	records, err := ReadCaptimeRecordsWithFilter(gameDB, func(key, value string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	c.stamp = stamp
+	c.records = records
+	return records, nil
+}
+
+// parseRotation combines maplist cvars with current map, maps set from gamedirs
+// and records from gamedb
+func parseRotation(cvars map[string]*rcon.Cvar, currentMap string, mapsSet map[string]bool, records Records) (*Rotation, error) {
+	rotation := Rotation{Current: currentMap, Maps: []RotationEntry{}}
+
+	maplist, ok := cvars[maplistCvar]
+	if !ok {
+		return nil, fmt.Errorf("Server doesn't have %s cvar", maplistCvar)
+	}
+	if cvar, ok := cvars[maplistShuffleCvar]; ok {
+		shuffle, err := cvar.Bool()
+		if err != nil {
+			return nil, err
+		}
+		rotation.Shuffle = shuffle
+	}
+	if cvar, ok := cvars[maplistVotableCvar]; ok {
+		votable, err := cvar.Int()
+		if err != nil {
+			return nil, err
+		}
+		rotation.Votable = votable
+	}
+	maps := maplist.List()
+	for i, mapname := range maps {
+		_, hasRecord := records[mapname]
+		entry := RotationEntry{
+			Map:       mapname,
+			Available: mapsSet[mapname],
+			HasRecord: hasRecord,
+			Current:   mapname == currentMap,
+		}
+		rotation.Maps = append(rotation.Maps, entry)
+		// next map is known only when it isn't selected by vote or shuffle
+		if entry.Current && !rotation.Shuffle && rotation.Votable == 0 {
+			rotation.Next = maps[(i+1)%len(maps)]
+		}
+	}
+	return &rotation, nil
+}
+
+func queryRotation(serverConf *rcon.ServerConfig) (*Rotation, error) {
+	var wg sync.WaitGroup
+	var statusErr, cvarsErr, recordsErr error
+	var status *rcon.ServerStatus
+	var cvars map[string]*rcon.Cvar
+	var records Records
+
+	conf := getConfig()
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		status, statusErr = rcon.QueryWithRetries(time.Millisecond*1000, 3,
+			func(deadline time.Time) (*rcon.ServerStatus, error) {
+				return rcon.QueryRconStatus(serverConf, deadline)
+			})
+	}()
+	go func() {
+		defer wg.Done()
+		cvars, cvarsErr = rcon.QueryWithRetries(time.Millisecond*1000, 3,
+			func(deadline time.Time) (map[string]*rcon.Cvar, error) {
+				return rcon.QueryCvars(serverConf, deadline,
+					maplistCvar, maplistShuffleCvar, maplistVotableCvar)
+			})
+	}()
+	go func() {
+		defer wg.Done()
+		records, recordsErr = rotationRecords.Get(conf.GameDB)
+	}()
+	wg.Wait()
+	if statusErr != nil {
+		return nil, statusErr
+	}
+	if cvarsErr != nil {
+		return nil, cvarsErr
+	}
+	if recordsErr != nil {
+		return nil, recordsErr
+	}
+	return parseRotation(cvars, status.Map, mapsState.GetMapsSet(), records)
+}
+
+func writeRotation(w http.ResponseWriter, rotation *Rotation) {
+	json, err := json.Marshal(rotation)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(json)
+}
+
+func rotation(w http.ResponseWriter, r *http.Request) {
+	conf := getConfig()
+	serverName := chi.URLParam(r, "server")
+	serverConf, ok := conf.PublicServer(serverName)
+	if !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	rotation, err := queryRotation(&serverConf)
+	if err != nil {
+		http.Error(w, "Can't load data from server", http.StatusInternalServerError)
+		return
+	}
+	writeRotation(w, rotation)
+}
+
+func updateRotation(w http.ResponseWriter, r *http.Request) {
+	var update RotationUpdate
+
+	conf := getConfig()
+	serverName := chi.URLParam(r, "server")
+	serverConf, ok := conf.Servers[serverName]
+	if !ok {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(update.Maps) == 0 {
+		http.Error(w, "Rotation should contain at least one map", http.StatusBadRequest)
+		return
+	}
+	mapsSet := mapsState.GetMapsSet()
+	for _, mapname := range update.Maps {
+		if !mapnameRe.MatchString(mapname) {
+			http.Error(w, fmt.Sprintf("Invalid map name %q", mapname), http.StatusBadRequest)
+			return
+		}
+		if !mapsSet[mapname] {
+			http.Error(w, fmt.Sprintf("Map %s is missing in gamedirs", mapname), http.StatusBadRequest)
+			return
+		}
+	}
+	cvars := []rcon.Cvar{{Name: maplistCvar, Value: strings.Join(update.Maps, " ")}}
+	if update.Shuffle != nil {
+		value := "0"
+		if *update.Shuffle {
+			value = "1"
+		}
+		cvars = append(cvars, rcon.Cvar{Name: maplistShuffleCvar, Value: value})
+	}
+	if update.Votable != nil {
+		if *update.Votable < 0 {
+			http.Error(w, "Votable maps count can't be negative", http.StatusBadRequest)
+			return
+		}
+		cvars = append(cvars, rcon.Cvar{Name: maplistVotableCvar, Value: strconv.FormatInt(*update.Votable, 10)})
+	}
+	deadline := time.Now().Add(time.Millisecond * 1000)
+	err = rcon.SetCvars(&serverConf, deadline, cvars...)
+	if err != nil {
+		http.Error(w, "Can't update rotation on server", http.StatusInternalServerError)
+		return
+	}
+	// server doesn't acknowledge cvar changes, so read rotation back
+	rotation, err := queryRotation(&serverConf)
+	if err != nil {
+		http.Error(w, "Can't load data from server", http.StatusInternalServerError)
+		return
+	}
+	if len(rotation.Maps) != len(update.Maps) {
+		http.Error(w, "Rotation wasn't updated on server", http.StatusBadGateway)
+		return
+	}
+	for i, entry := range rotation.Maps {
+		if entry.Map != update.Maps[i] {
+			http.Error(w, "Rotation wasn't updated on server", http.StatusBadGateway)
+			return
+		}
+	}
+	writeRotation(w, rotation)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+func rotationCvars(maplist, shuffle, votable string) map[string]*rcon.Cvar {
+	return map[string]*rcon.Cvar{
+		maplistCvar:        {Name: maplistCvar, Value: maplist},
+		maplistShuffleCvar: {Name: maplistShuffleCvar, Value: shuffle},
+		maplistVotableCvar: {Name: maplistVotableCvar, Value: votable},
+	}
+}
+
+func TestParseRotation(t *testing.T) {
+	mapsSet := map[string]bool{"bloodrage": true, "dance": true}
+	records := Records{"dance": &RecordItem{}}
+	rotation, err := parseRotation(rotationCvars("bloodrage dance  space", "0", "0"), "space", mapsSet, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []RotationEntry{
+		{Map: "bloodrage", Available: true},
+		{Map: "dance", Available: true, HasRecord: true},
+		{Map: "space", Current: true},
+	}
+	if len(rotation.Maps) != len(expected) {
+		t.Fatal("Incorrect maps ", rotation.Maps)
+	}
+	for i, entry := range rotation.Maps {
+		if entry != expected[i] {
+			t.Error("Incorrect entry ", entry)
+		}
+	}
+	if rotation.Current != "space" || rotation.Next != "bloodrage" {
+		t.Error("Incorrect current or next map ", rotation.Current, rotation.Next)
+	}
+	validateOpenAPI(t, "Rotation", rotation)
+
+	// next map isn't known with shuffle or voting
+	for _, cvars := range []map[string]*rcon.Cvar{
+		rotationCvars("bloodrage dance", "1", "0"),
+		rotationCvars("bloodrage dance", "0", "5"),
+	} {
+		rotation, err := parseRotation(cvars, "bloodrage", mapsSet, records)
+		if err != nil || rotation.Next != "" {
+			t.Error("Next map shouldn't be known ", rotation, err)
+		}
+	}
+}
+
+func TestParseRotationErrors(t *testing.T) {
+	if _, err := parseRotation(map[string]*rcon.Cvar{}, "dance", nil, nil); err == nil {
+		t.Error("Missing maplist should be rejected")
+	}
+	if _, err := parseRotation(rotationCvars("dance", "yes", "0"), "dance", nil, nil); err == nil {
+		t.Error("Invalid shuffle should be rejected")
+	}
+	if _, err := parseRotation(rotationCvars("dance", "0", "x"), "dance", nil, nil); err == nil {
+		t.Error("Invalid votable should be rejected")
+	}
+}
+
+func TestUpdateRotationValidation(t *testing.T) {
+	dir := t.TempDir()
+	file, err := os.Create(filepath.Join(dir, "maps.pk3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arc := zip.NewWriter(file)
+	arc.Create("maps/dance.bsp")
+	arc.Close()
+	file.Close()
+	defer func(state *MapsState) {
+		mapsState = state
+	}(mapsState)
+	mapsState = &MapsState{gameDirs: func() []string { return []string{dir} }}
+
+	token := "0123456789abcdef"
+	config.Store(&Config{
+		Servers:    map[string]rcon.ServerConfig{"ctf": {Server: "127.0.0.1", Port: 26000}},
+		AdminToken: rcon.Secret(token),
+	})
+	put := func(target, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		webService().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := put("/api/v1/servers/ctf/rotation", `{"maps": ["dance"]}`, ""); w.Code != http.StatusUnauthorized {
+		t.Error("Request without token should be rejected ", w.Code)
+	}
+	if w := put("/api/v1/servers/dm/rotation", `{"maps": ["dance"]}`, token); w.Code != http.StatusNotFound {
+		t.Error("Unknown server should be rejected ", w.Code)
+	}
+	cases := map[string]string{
+		`{"maps": `:                              "Invalid request",
+		`{"maps": []}`:                           "at least one map",
+		`{"maps": ["../dance"]}`:                 "Invalid map name",
+		`{"maps": ["dance", "space"]}`:           "missing in gamedirs",
+		`{"maps": ["dance"], "votable": -1}`:     "can't be negative",
+		`{"maps": ["dance"], "shuffle": "true"}`: "Invalid request",
+	}
+	for body, message := range cases {
+		w := put("/api/v1/servers/ctf/rotation", body, token)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), message) {
+			t.Errorf("Incorrect response for %s: %d %s", body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRotationRecordsCache(t *testing.T) {
+	var cache RotationRecordsCache
+
+	path := writeTestGameDB(t, 2)
+	records, err := cache.Get([]string{path})
+	if err != nil || len(records) != 2 {
+		t.Fatal("Incorrect records ", records, err)
+	}
+	records["map0"] = nil
+	if cached, _ := cache.Get([]string{path}); cached["map0"] != nil {
+		t.Error("Unchanged gamedb should be cached")
+	}
+	os.Rename(writeTestGameDB(t, 3), path)
+	if records, _ := cache.Get([]string{path}); len(records) != 3 {
+		t.Error("Changed gamedb should be read again ", len(records))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	// metrics is name of game servers metrics handler
+	selfmetrics "github.com/TheRegulars/website/backend/pkg/metrics"
+	"github.com/go-chi/chi/v5"
+)
+
+// backend metrics, rcon metrics are registered by pkg/rcon
+var (
+	httpRequestsTotal = selfmetrics.Default.NewCounter("backend_http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	httpRequestDuration = selfmetrics.Default.NewHistogram("backend_http_request_duration_seconds",
+		"HTTP request latency by method and route.", selfmetrics.DefaultBuckets, "method", "route")
+	mapsCacheTotal = selfmetrics.Default.NewCounter("backend_maps_cache_requests_total",
+		"Lookups of pk3 files in maps cache, miss means that pk3 was read.", "result")
+	gamedbReadDuration = selfmetrics.Default.NewHistogram("backend_gamedb_read_duration_seconds",
+		"Duration of reading records from gamedb file.", selfmetrics.DefaultBuckets, "gametype")
+)
+
+// requestMetrics counts requests by chi route pattern
+func requestMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		httpRequestsTotal.Inc(r.Method, route, strconv.Itoa(sw.status))
+		httpRequestDuration.ObserveDuration(start, r.Method, route)
+	})
+}
+
+func registerRuntimeMetrics() {
+	selfmetrics.Default.RegisterRuntime()
+}
+
+func internalMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	selfmetrics.Default.WriteTo(w)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInternalMetrics(t *testing.T) {
+	serveTest("GET", "/api/v1/servers/missing/status", nil)
+	body := serveTest("GET", "/internal/metrics", nil).Body.String()
+	expected := `backend_http_requests_total{method="GET",route="/api/v1/servers/{server}/status",status="404"}`
+	if !strings.Contains(body, expected) {
+		t.Errorf("Request metric is missing:\n%s", body)
+	}
+	if !strings.Contains(body, `backend_http_request_duration_seconds_count{method="GET",route="/api/v1/servers/{server}/status"}`) {
+		t.Error("Latency histogram is missing")
+	}
+}
+
+func TestMapsCacheMetrics(t *testing.T) {
+	dir := t.TempDir()
+	file, err := os.Create(filepath.Join(dir, "maps.pk3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arc := zip.NewWriter(file)
+	if _, err := arc.Create("maps/bloodrage.bsp"); err != nil {
+		t.Fatal(err)
+	}
+	arc.Close()
+	file.Close()
+
+	state := &MapsState{gameDirs: func() []string { return []string{dir} }}
+	hits, misses := mapsCacheTotal.Value("hit"), mapsCacheTotal.Value("miss")
+	for i := 0; i < 3; i++ {
+		if maps := state.GetMapsSet(); !maps["bloodrage"] {
+			t.Fatal("Map wasn't found ", maps)
+		}
+	}
+	if mapsCacheTotal.Value("miss")-misses != 1 || mapsCacheTotal.Value("hit")-hits != 2 {
+		t.Error("Incorrect cache stats ", mapsCacheTotal.Value("hit")-hits, mapsCacheTotal.Value("miss")-misses)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+// serversTimeout is deadline for all servers of /servers?expand=, late servers are reported offline
+const serversTimeout = time.Second * 2
+
+// ServerExpand lists parts of server queried for /servers?expand=
+type ServerExpand struct {
+	Status bool
+	Info   bool
+	Scores bool
+}
+
+// ServerDetails is server entry of /servers?expand=, failed server has only error
+type ServerDetails struct {
+	Online bool               `json:"online"`
+	Error  string             `json:"error,omitempty"`
+	Status *rcon.ServerStatus `json:"status,omitempty"`
+	Info   *rcon.ServerInfo   `json:"info,omitempty"`
+	Scores *rcon.ServerScores `json:"scores,omitempty"`
+	// players is number of humans, status is always queried for totals
+	players int
+}
+
+type ServersTotals struct {
+	Players   int `json:"players"`
+	ServersUp int `json:"servers_up"`
+	Servers   int `json:"servers"`
+	// most populated server is omitted when nobody plays
+	MostPopulated string `json:"most_populated,omitempty"`
+}
+
+// ServerMeta is display metadata of server from config
+type ServerMeta struct {
+	// Name is display name, it's key of server when it isn't configured
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Region      string            `json:"region,omitempty"`
+	Category    string            `json:"category,omitempty"`
+	JoinURL     string            `json:"join_url"`
+	Order       int               `json:"order"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// ServersList is /servers response, hidden and internal servers aren't listed
+type ServersList struct {
+	Servers []string               `json:"servers"`
+	Display map[string]*ServerMeta `json:"display"`
+}
+
+// ServersExpanded is /servers?expand= response
+type ServersExpanded struct {
+	ServersList
+	Details map[string]*ServerDetails `json:"details"`
+	Totals  ServersTotals             `json:"totals"`
+}
+
+// PublicServer returns server for public routes, internal servers aren't available there
+func (c *Config) PublicServer(name string) (rcon.ServerConfig, bool) {
+	serverConf, ok := c.Servers[name]
+	if !ok || serverConf.Visibility() == rcon.VisibilityInternal {
+		return rcon.ServerConfig{}, false
+	}
+	return serverConf, true
+}
+
+// listedServers returns public servers sorted by display order, servers with same order are sorted by name
+func listedServers(servers map[string]rcon.ServerConfig) []string {
+	names := make([]string, 0, len(servers))
+	for name, serverConf := range servers {
+		if serverConf.Visibility() == rcon.VisibilityPublic {
+			names = append(names, name)
+		}
+	}
+	order := func(name string) int {
+		if display := servers[name].Display; display != nil {
+			return display.Order
+		}
+		return 0
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if order(names[i]) != order(names[j]) {
+			return order(names[i]) < order(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func newServerMeta(name string, serverConf *rcon.ServerConfig) *ServerMeta {
+	meta := &ServerMeta{Name: name, JoinURL: serverConf.JoinURL(), Labels: serverConf.Labels}
+	if display := serverConf.Display; display != nil {
+		if display.Name != "" {
+			meta.Name = display.Name
+		}
+		meta.Description = display.Description
+		meta.Region = display.Region
+		meta.Category = display.Category
+		meta.Order = display.Order
+	}
+	return meta
+}
+
+func newServersList(servers map[string]rcon.ServerConfig) ServersList {
+	list := ServersList{Servers: listedServers(servers), Display: make(map[string]*ServerMeta)}
+	for _, name := range list.Servers {
+		serverConf := servers[name]
+		list.Display[name] = newServerMeta(name, &serverConf)
+	}
+	return list
+}
+
+var exporterLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// exporterLabels formats labels of metrics exporter, instance label is name of server
+func exporterLabels(name string, labels, extra map[string]string) template.HTML {
+	parts := []string{`instance="` + exporterLabelEscaper.Replace(name) + `"`}
+	for _, set := range []map[string]string{labels, extra} {
+		keys := make([]string, 0, len(set))
+		for key := range set {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts = append(parts, key+`="`+exporterLabelEscaper.Replace(set[key])+`"`)
+		}
+	}
+	return template.HTML("{" + strings.Join(parts, ",") + "}")
+}
+
+type fetchServerFunc func(serverConf *rcon.ServerConfig, expand ServerExpand, deadline time.Time) *ServerDetails
+
+func parseServerExpand(value string) (ServerExpand, error) {
+	var expand ServerExpand
+
+	for _, part := range strings.Split(value, ",") {
+		switch strings.TrimSpace(part) {
+		case "status":
+			expand.Status = true
+		case "info":
+			expand.Info = true
+		case "scores":
+			expand.Scores = true
+		default:
+			return expand, fmt.Errorf("Unknown expand %q", part)
+		}
+	}
+	return expand, nil
+}
+
+// queryBefore limits deadline of every retry, so retries stop at global deadline
+func queryBefore[T any](deadline time.Time, query func(deadline time.Time) (*T, error)) (*T, error) {
+	return rcon.QueryWithRetries(time.Millisecond*1000, 3, func(retryDeadline time.Time) (*T, error) {
+		if deadline.Before(retryDeadline) {
+			retryDeadline = deadline
+		}
+		return query(retryDeadline)
+	})
+}
+
+func serverError(err error) string {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return "Server didn't respond in time"
+	}
+	return "Can't load data from server"
+}
+
+// fetchServer queries parts of server concurrently, server is offline when any of them fails
+func fetchServer(serverConf *rcon.ServerConfig, expand ServerExpand, deadline time.Time) *ServerDetails {
+	var statusErr, infoErr, scoresErr error
+
+	details := &ServerDetails{}
+	done := make(chan struct{}, 3)
+	go func() {
+		details.Status, statusErr = queryBefore(deadline, func(deadline time.Time) (*rcon.ServerStatus, error) {
+			return rcon.QueryRconStatus(serverConf, deadline)
+		})
+		done <- struct{}{}
+	}()
+	go func() {
+		if expand.Info {
+			details.Info, infoErr = queryBefore(deadline, func(deadline time.Time) (*rcon.ServerInfo, error) {
+				return rcon.QueryRconInfo(serverConf, deadline)
+			})
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		if expand.Scores {
+			details.Scores, scoresErr = queryBefore(deadline, func(deadline time.Time) (*rcon.ServerScores, error) {
+				return rcon.QueryRconScores(serverConf, deadline)
+			})
+		}
+		done <- struct{}{}
+	}()
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+	if err := errors.Join(statusErr, infoErr, scoresErr); err != nil {
+		return &ServerDetails{Error: serverError(err)}
+	}
+	geoIPState.AnnotateCountries(details.Status)
+	details.Online = true
+	details.players = rcon.CountPlayers(details.Status).Humans()
+	if !expand.Status {
+		details.Status = nil
+	}
+	return details
+}
+
+// fetchServers queries listed servers concurrently, servers without response till deadline are offline
+func fetchServers(servers map[string]rcon.ServerConfig, expand ServerExpand, timeout time.Duration, fetch fetchServerFunc) *ServersExpanded {
+	type result struct {
+		name    string
+		details *ServerDetails
+	}
+
+	deadline := time.Now().Add(timeout)
+	// buffered, so late servers don't block
+	results := make(chan result, len(servers))
+	response := &ServersExpanded{
+		ServersList: newServersList(servers),
+		Details:     make(map[string]*ServerDetails),
+	}
+	for _, name := range response.Servers {
+		response.Details[name] = &ServerDetails{Error: "Server didn't respond in time"}
+		go func(name string, serverConf rcon.ServerConfig) {
+			results <- result{name, fetch(&serverConf, expand, deadline)}
+		}(name, servers[name])
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+wait:
+	for range response.Servers {
+		select {
+		case r := <-results:
+			response.Details[r.name] = r.details
+		case <-timer.C:
+			break wait
+		}
+	}
+	response.Totals = serversTotals(response)
+	return response
+}
+
+func serversTotals(response *ServersExpanded) ServersTotals {
+	totals := ServersTotals{Servers: len(response.Servers)}
+	mostPlayers := 0
+	// ties are resolved by order of servers
+	for _, name := range response.Servers {
+		details := response.Details[name]
+		if !details.Online {
+			continue
+		}
+		totals.ServersUp++
+		totals.Players += details.players
+		if details.players > mostPlayers {
+			mostPlayers = details.players
+			totals.MostPopulated = name
+		}
+	}
+	return totals
+}
+
+// serversExpanded is /servers?expand= handler
+func serversExpanded(w http.ResponseWriter, r *http.Request, value string) {
+	expand, err := parseServerExpand(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := fetchServers(getConfig().Servers, expand, serversTimeout, fetchServer)
+	json, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// details are live data unlike list of names
+	w.Header().Set("Cache-Control", cacheLive)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(json)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/rcon"
+)
+
+func TestParseServerExpand(t *testing.T) {
+	expand, err := parseServerExpand("status, scores")
+	if err != nil || expand != (ServerExpand{Status: true, Scores: true}) {
+		t.Error("Incorrect expand ", expand, err)
+	}
+	if _, err := parseServerExpand("status,players"); err == nil {
+		t.Error("Unknown part should be rejected")
+	}
+	if w := serveTest("GET", "/api/v1/servers?expand=rotation", nil); w.Code != http.StatusBadRequest {
+		t.Error("Incorrect status ", w.Code)
+	}
+}
+
+func TestFetchServers(t *testing.T) {
+	servers := map[string]rcon.ServerConfig{
+		"ctf":  {Server: "127.0.0.1", Port: 26000},
+		"dm":   {Server: "127.0.0.1", Port: 26001},
+		"duel": {Server: "127.0.0.1", Port: 26002},
+		"race": {Server: "127.0.0.1", Port: 26003},
+	}
+	players := map[int]int{26000: 3, 26001: 5, 26003: 5}
+	fetch := func(serverConf *rcon.ServerConfig, expand ServerExpand, deadline time.Time) *ServerDetails {
+		switch serverConf.Port {
+		case 26002:
+			return &ServerDetails{Error: "Can't load data from server"}
+		case 26003:
+			// answers after deadline
+			time.Sleep(time.Until(deadline) + time.Millisecond*50)
+		}
+		status := &rcon.ServerStatus{Map: "bloodrage"}
+		for i := 0; i < players[serverConf.Port]; i++ {
+			status.Players = append(status.Players, rcon.Player{Type: rcon.PlayerTypePlayer})
+		}
+		status.Players = append(status.Players, rcon.Player{Type: rcon.PlayerTypeBot, IsBot: true})
+		return &ServerDetails{
+			Online:  true,
+			Status:  status,
+			Scores:  testServerScores(),
+			players: rcon.CountPlayers(status).Humans(),
+		}
+	}
+
+	start := time.Now()
+	response := fetchServers(servers, ServerExpand{Status: true, Scores: true}, time.Millisecond*100, fetch)
+	if time.Since(start) > time.Millisecond*140 {
+		t.Error("Slow server delayed response ", time.Since(start))
+	}
+	if len(response.Servers) != 4 || response.Servers[0] != "ctf" || response.Servers[3] != "race" {
+		t.Error("Incorrect servers ", response.Servers)
+	}
+	if !response.Details["ctf"].Online || !response.Details["dm"].Online {
+		t.Error("Servers should be online ", response.Details)
+	}
+	if details := response.Details["duel"]; details.Online || details.Error != "Can't load data from server" {
+		t.Error("Failed server should be offline ", details)
+	}
+	if details := response.Details["race"]; details.Online || details.Error != "Server didn't respond in time" {
+		t.Error("Late server should be offline ", details)
+	}
+	expected := ServersTotals{Players: 8, ServersUp: 2, Servers: 4, MostPopulated: "dm"}
+	if response.Totals != expected {
+		t.Error("Incorrect totals ", response.Totals)
+	}
+	validateOpenAPI(t, "ServersList", response)
+}
+
+func TestServersTotalsEmpty(t *testing.T) {
+	response := &ServersExpanded{
+		ServersList: ServersList{Servers: []string{"ctf"}},
+		Details:     map[string]*ServerDetails{"ctf": {Online: true}},
+	}
+	if totals := serversTotals(response); totals != (ServersTotals{ServersUp: 1, Servers: 1}) {
+		t.Error("Empty servers shouldn't be most populated ", totals)
+	}
+}
+
+func TestServersDisplay(t *testing.T) {
+	servers := map[string]rcon.ServerConfig{
+		"ctf":   {Server: "127.0.0.1", Port: 26000, Display: &rcon.ServerDisplay{Name: "Regulars CTF", Region: "eu", Order: 2}},
+		"dm":    {Server: "127.0.0.1", Port: 26001, Labels: map[string]string{"mode": "dm"}},
+		"duel":  {Server: "127.0.0.1", Port: 26002},
+		"tests": {Server: "127.0.0.1", Port: 26003, Display: &rcon.ServerDisplay{Visibility: rcon.VisibilityHidden}},
+		"local": {Server: "127.0.0.1", Port: 26004, Display: &rcon.ServerDisplay{Visibility: rcon.VisibilityInternal}},
+		"race":  {Server: "::1", Port: 26005, Display: &rcon.ServerDisplay{JoinURL: "xonotic://race.example.com:26000", Order: -1}},
+	}
+	list := newServersList(servers)
+	if strings.Join(list.Servers, ",") != "race,dm,duel,ctf" {
+		t.Error("Incorrect order ", list.Servers)
+	}
+	ctf := list.Display["ctf"]
+	if ctf.Name != "Regulars CTF" || ctf.Region != "eu" || ctf.JoinURL != "xonotic://127.0.0.1:26000" {
+		t.Error("Incorrect display ", ctf)
+	}
+	if dm := list.Display["dm"]; dm.Name != "dm" || dm.Labels["mode"] != "dm" {
+		t.Error("Incorrect defaults ", dm)
+	}
+	if race := list.Display["race"]; race.JoinURL != "xonotic://race.example.com:26000" {
+		t.Error("Incorrect join url ", race.JoinURL)
+	}
+	validateOpenAPI(t, "ServersList", list)
+
+	conf := &Config{Servers: servers}
+	if _, ok := conf.PublicServer("tests"); !ok {
+		t.Error("Hidden server should be available by name")
+	}
+	if _, ok := conf.PublicServer("local"); ok {
+		t.Error("Internal server shouldn't be public")
+	}
+}
+
+func TestExporterLabels(t *testing.T) {
+	labels := exporterLabels("ctf", map[string]string{"region": "eu", "mode": `"ctf"`}, map[string]string{"from": "host"})
+	if labels != `{instance="ctf",mode="\"ctf\"",region="eu",from="host"}` {
+		t.Error("Incorrect labels ", labels)
+	}
+	if labels := exporterLabels("ctf", nil, nil); labels != `{instance="ctf"}` {
+		t.Error("Incorrect labels ", labels)
+	}
+}
+
+func TestServerDisplayConfig(t *testing.T) {
+	serverConf := rcon.ServerConfig{
+		Server:       "127.0.0.1",
+		Port:         26000,
+		RconPassword: "secret",
+		Display:      &rcon.ServerDisplay{Name: "CTF", JoinURL: "xonotic://ctf.example.com:26000", Visibility: rcon.VisibilityHidden},
+		Labels:       map[string]string{"region": "eu"},
+	}
+	conf := &Config{Servers: map[string]rcon.ServerConfig{"ctf": serverConf}, GameDB: []string{"server.db"}}
+	if !validateConfig(conf) {
+		t.Error("Valid config is rejected")
+	}
+	for _, labels := range []map[string]string{{"instance": "other"}, {"region-name": "eu"}} {
+		serverConf.Labels = labels
+		conf.Servers["ctf"] = serverConf
+		if validateConfig(conf) {
+			t.Error("Invalid labels are accepted ", labels)
+		}
+	}
+	serverConf.Labels = nil
+	serverConf.Display = &rcon.ServerDisplay{Visibility: "secret"}
+	conf.Servers["ctf"] = serverConf
+	if validateConfig(conf) {
+		t.Error("Invalid visibility is accepted")
+	}
+}
+
+func TestExportersAdminOnly(t *testing.T) {
+	config.Store(&Config{Servers: map[string]rcon.ServerConfig{
+		"ctf":   {Server: "127.0.0.1", Port: 26000},
+		"local": {Server: "127.0.0.1", Port: 26004, Display: &rcon.ServerDisplay{Visibility: rcon.VisibilityInternal}},
+	}})
+	get := func(scope routeScope) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		newRouter(scope).ServeHTTP(w, httptest.NewRequest("GET", "/exporters", nil))
+		return w
+	}
+	if w := get(publicRoutes); w.Code != http.StatusNotFound {
+		t.Error("Exporters page shouldn't be public ", w.Code)
+	}
+	w := get(adminRoutes)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "target=ctf") || !strings.Contains(body, "target=local") {
+		t.Error("Admin listener should list servers with metrics ", w.Code, body)
+	}
+}
@@ -0,0 +1,120 @@
+// Package client is typed client of backend API, types and methods
+// in client_gen.go are generated from cmd/openapi.json
+package client
+
+//go:generate go run ../../cmd/apigen -spec ../../cmd/openapi.json -out client_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorSize limits size of error message read from response
+const maxErrorSize = 4096
+
+type Client struct {
+	// BaseURL is API root like https://example.com/api/v1
+	BaseURL string
+	// Token is sent as bearer token, it's admin, chat or subscription token
+	Token      string
+	HTTPClient *http.Client
+}
+
+// Error is returned for unsuccessful responses
+type Error struct {
+	StatusCode int
+	// Code is snake case status like not_found
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// decodeError reads error envelope, deprecated routes without /api/v1 prefix return plain text
+func decodeError(resp *http.Response) *Error {
+	var envelope ErrorResponse
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+	if json.Unmarshal(message, &envelope) == nil && envelope.Error != nil {
+		return &Error{StatusCode: resp.StatusCode, Code: envelope.Error.Code, Message: envelope.Error.Message}
+	}
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(resp.StatusCode)), " ", "_")
+	return &Error{StatusCode: resp.StatusCode, Code: code, Message: strings.TrimSpace(string(message))}
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// do sends request and decodes json response into result when it's not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	resp, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// doRaw sends request and returns response body
+func (c *Client) doRaw(ctx context.Context, method, path string, query url.Values, body interface{}) ([]byte, error) {
+	resp, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
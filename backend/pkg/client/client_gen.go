@@ -0,0 +1,759 @@
+// Code generated by apigen from openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type APIError struct {
+	// Code is snake case HTTP status like not_found
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Ban struct {
+	Address   string  `json:"address"`
+	ID        int64   `json:"id"`
+	Remaining float64 `json:"remaining"`
+	Type      string  `json:"type"`
+}
+
+type BanErrors struct {
+	// Errors is errors by server name
+	Errors map[string]string `json:"errors"`
+}
+
+type BanRequest struct {
+	Address  string `json:"address"`
+	Duration int64  `json:"duration"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Config is loaded configuration, secrets are redacted
+type Config map[string]interface{}
+
+type Cvar struct {
+	Default string `json:"default"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+}
+
+type CvarsList struct {
+	Cvars map[string]Cvar `json:"cvars"`
+}
+
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+type MapsList []string
+
+// OpenAPI is this document
+type OpenAPI map[string]interface{}
+
+type OutgoingChatMessage struct {
+	Message string `json:"message"`
+	Name    string `json:"name"`
+}
+
+type Player struct {
+	// Country is ISO country code, it's present when GeoIP database is configured
+	Country string `json:"country,omitempty"`
+	Frags   int64  `json:"frags"`
+	IsBot   bool   `json:"is_bot"`
+	Name    string `json:"name"`
+	No      int32  `json:"no"`
+	Ping    int64  `json:"ping"`
+	Pl      int64  `json:"pl"`
+	Time    int64  `json:"time"`
+	Type    string `json:"type"`
+}
+
+type PlayerScores struct {
+	ID          int32     `json:"id"`
+	Name        string    `json:"name"`
+	PlayingTime int64     `json:"playing_time"`
+	Scores      []float64 `json:"scores"`
+	TeamID      int32     `json:"team_id"`
+}
+
+type Population struct {
+	From   time.Time         `json:"from"`
+	Points []PopulationPoint `json:"points"`
+	Server string            `json:"server"`
+	Step   int64             `json:"step"`
+	To     time.Time         `json:"to"`
+}
+
+type PopulationHeatmap struct {
+	// Hours is average humans count by weekday starting from Sunday and hour
+	Hours    [][]float64 `json:"hours"`
+	Server   string      `json:"server"`
+	Timezone string      `json:"timezone"`
+	Weeks    int64       `json:"weeks"`
+}
+
+type PopulationPoint struct {
+	Active     float64   `json:"active"`
+	Bots       float64   `json:"bots"`
+	Samples    int64     `json:"samples"`
+	Spectators float64   `json:"spectators"`
+	Time       time.Time `json:"time"`
+}
+
+type PushSubscription struct {
+	Endpoint string `json:"endpoint"`
+}
+
+type RecordEntries []RecordEntry
+
+type RecordEntry struct {
+	Date *time.Time `json:"date,omitempty"`
+	Map  string     `json:"map"`
+	Name string     `json:"name"`
+	Val  float64    `json:"val"`
+}
+
+type RecordEvent struct {
+	ID          string    `json:"id"`
+	Improvement float64   `json:"improvement"`
+	Map         string    `json:"map"`
+	NewHolder   string    `json:"new_holder"`
+	NewTime     float64   `json:"new_time"`
+	OldHolder   string    `json:"old_holder"`
+	OldTime     float64   `json:"old_time"`
+	Time        time.Time `json:"time"`
+}
+
+type RecordItem struct {
+	Name string  `json:"name"`
+	Val  float64 `json:"val"`
+}
+
+// Records is best records by map name
+type Records map[string]RecordItem
+
+type RecordsFeed struct {
+	Events []RecordEvent `json:"events"`
+}
+
+type Rotation struct {
+	Current string          `json:"current"`
+	Maps    []RotationEntry `json:"maps"`
+	Next    string          `json:"next,omitempty"`
+	Shuffle bool            `json:"shuffle"`
+	// Votable is number of maps offered in end of match vote, value of g_maplist_votable. State of running vote isn't reported
+	Votable int64 `json:"votable"`
+}
+
+type RotationEntry struct {
+	Available bool   `json:"available"`
+	Current   bool   `json:"current"`
+	HasRecord bool   `json:"has_record"`
+	Map       string `json:"map"`
+}
+
+type RotationUpdate struct {
+	Maps    []string `json:"maps"`
+	Shuffle *bool    `json:"shuffle,omitempty"`
+	// Votable is number of maps offered in end of match vote, value of g_maplist_votable
+	Votable *int64 `json:"votable,omitempty"`
+}
+
+type ScoreLabel struct {
+	LowerIsBetter bool   `json:"lower_is_better"`
+	Name          string `json:"name"`
+	Primary       bool   `json:"primary"`
+	Secondary     bool   `json:"secondary"`
+}
+
+type ServerAll struct {
+	ServerStatus
+	Info   *ServerInfo   `json:"info"`
+	Scores *ServerScores `json:"scores"`
+}
+
+type ServerBansList struct {
+	Bans []Ban `json:"bans"`
+}
+
+type ServerDetails struct {
+	// Error is reason why server is offline
+	Error  string        `json:"error,omitempty"`
+	Info   *ServerInfo   `json:"info,omitempty"`
+	Online bool          `json:"online"`
+	Scores *ServerScores `json:"scores,omitempty"`
+	Status *ServerStatus `json:"status,omitempty"`
+}
+
+type ServerFlags struct {
+	AllowFullbright   bool `json:"allow_fullbright"`
+	PlayerStats       bool `json:"player_stats"`
+	PlayerStatsCustom bool `json:"player_stats_custom"`
+	Teamplay          bool `json:"teamplay"`
+}
+
+type ServerInfo struct {
+	Flags            *ServerFlags `json:"flags"`
+	Gametype         string       `json:"gametype"`
+	JoinAllowedCount int64        `json:"join_allowed_count"`
+	ModName          string       `json:"mod_name"`
+	PlayerLabels     []ScoreLabel `json:"player_labels"`
+	PureChangesCount int64        `json:"pure_changes_count"`
+	ScoreString      string       `json:"score_string"`
+	ServerFlags      int32        `json:"server_flags"`
+	TeamLabels       []ScoreLabel `json:"team_labels"`
+	TeamScores       []TeamScore  `json:"team_scores"`
+	TermsOfService   string       `json:"terms_of_service"`
+	Version          string       `json:"version"`
+}
+
+type ServerInfoV2 struct {
+	ServerInfo
+	Mutators []string `json:"mutators"`
+}
+
+type ServerMeta struct {
+	// Category is gametype category
+	Category    string `json:"category,omitempty"`
+	Description string `json:"description,omitempty"`
+	// JoinURL is URL like xonotic://host:port
+	JoinURL string            `json:"join_url"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	// Name is display name, it's server name when it isn't configured
+	Name   string `json:"name"`
+	Order  int64  `json:"order"`
+	Region string `json:"region,omitempty"`
+}
+
+type ServerScores struct {
+	GameTime     int64          `json:"game_time"`
+	Gametype     string         `json:"gametype"`
+	Map          string         `json:"map"`
+	PlayerLabels []string       `json:"player_labels"`
+	Players      []PlayerScores `json:"players"`
+	TeamLabels   []string       `json:"team_labels"`
+	// TeamScores is scores by team id
+	TeamScores map[string][]int64 `json:"team_scores"`
+}
+
+type ServerStatus struct {
+	// Address is resolved ip:port of server
+	Address      string        `json:"address,omitempty"`
+	Host         string        `json:"host"`
+	Map          string        `json:"map"`
+	Players      []Player      `json:"players,omitempty"`
+	PlayersCount int64         `json:"players_count"`
+	PlayersMax   int64         `json:"players_max"`
+	Protocol     string        `json:"protocol"`
+	SvPublic     int32         `json:"sv_public"`
+	Timing       *ServerTiming `json:"timing"`
+	Version      string        `json:"version"`
+}
+
+type ServerTiming struct {
+	CPU        float64 `json:"cpu"`
+	Lost       float64 `json:"lost"`
+	OffsetAvg  float64 `json:"offset_avg"`
+	OffsetMax  float64 `json:"offset_max"`
+	OffsetSdev float64 `json:"offset_sdev"`
+}
+
+type ServersList struct {
+	// Details is servers by name, only with expand
+	Details map[string]ServerDetails `json:"details,omitempty"`
+	// Display is display metadata by server name
+	Display map[string]ServerMeta `json:"display"`
+	// Servers is names of public servers sorted by display order and name
+	Servers []string       `json:"servers"`
+	Totals  *ServersTotals `json:"totals,omitempty"`
+}
+
+type ServersTotals struct {
+	// MostPopulated is server with most players, omitted when nobody plays
+	MostPopulated string `json:"most_populated,omitempty"`
+	// Players is human players on online servers
+	Players   int64 `json:"players"`
+	Servers   int64 `json:"servers"`
+	ServersUp int64 `json:"servers_up"`
+}
+
+type SharedBan struct {
+	Address string    `json:"address"`
+	Expires time.Time `json:"expires"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+type SharedBanCreated struct {
+	Ban *SharedBan `json:"ban"`
+	// Errors is errors by server name
+	Errors map[string]string `json:"errors"`
+}
+
+type SharedBansList struct {
+	Bans []SharedBan `json:"bans"`
+}
+
+type Subscription struct {
+	Created   *time.Time        `json:"created,omitempty"`
+	ID        string            `json:"id,omitempty"`
+	Push      *PushSubscription `json:"push,omitempty"`
+	Server    string            `json:"server"`
+	Threshold int64             `json:"threshold"`
+	UserHash  string            `json:"user_hash,omitempty"`
+	Webhook   string            `json:"webhook,omitempty"`
+}
+
+type SubscriptionCreated struct {
+	Subscription *Subscription `json:"subscription"`
+	Token        string        `json:"token"`
+}
+
+type SubscriptionsList struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+type TeamScore struct {
+	Scores []int64 `json:"scores"`
+	Team   int64   `json:"team"`
+}
+
+type VapidKey struct {
+	Key string `json:"key"`
+}
+
+// ListSharedBans requests GET /admin/bans
+//
+// Shared ban list
+func (c *Client) ListSharedBans(ctx context.Context) (*SharedBansList, error) {
+	var result SharedBansList
+	if err := c.do(ctx, "GET", "/admin/bans", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddSharedBan requests POST /admin/bans
+//
+// Ban address on all servers
+func (c *Client) AddSharedBan(ctx context.Context, body *BanRequest) (*SharedBanCreated, error) {
+	var result SharedBanCreated
+	if err := c.do(ctx, "POST", "/admin/bans", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RemoveSharedBan requests DELETE /admin/bans/{address}
+//
+// Lift ban on all servers
+func (c *Client) RemoveSharedBan(ctx context.Context, address string) (*BanErrors, error) {
+	var result BanErrors
+	if err := c.do(ctx, "DELETE", "/admin/bans/"+url.PathEscape(address), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetAdminConfig requests GET /admin/config
+//
+// Loaded configuration
+func (c *Client) GetAdminConfig(ctx context.Context) (Config, error) {
+	var result Config
+	err := c.do(ctx, "GET", "/admin/config", nil, nil, &result)
+	return result, err
+}
+
+// ListServerBans requests GET /admin/servers/{server}/bans
+//
+// Bans of server
+func (c *Client) ListServerBans(ctx context.Context, server string) (*ServerBansList, error) {
+	var result ServerBansList
+	if err := c.do(ctx, "GET", "/admin/servers/"+url.PathEscape(server)+"/bans", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddServerBan requests POST /admin/servers/{server}/bans
+//
+// Ban address on server
+func (c *Client) AddServerBan(ctx context.Context, server string, body *BanRequest) (*ServerBansList, error) {
+	var result ServerBansList
+	if err := c.do(ctx, "POST", "/admin/servers/"+url.PathEscape(server)+"/bans", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RemoveServerBan requests DELETE /admin/servers/{server}/bans/{address}
+//
+// Lift ban on server
+func (c *Client) RemoveServerBan(ctx context.Context, server string, address string) error {
+	return c.do(ctx, "DELETE", "/admin/servers/"+url.PathEscape(server)+"/bans/"+url.PathEscape(address), nil, nil, nil)
+}
+
+// GetExporters requests GET /exporters
+//
+// Prometheus exporters page, admin only: served by admin listener with metrics
+func (c *Client) GetExporters(ctx context.Context) ([]byte, error) {
+	return c.doRaw(ctx, "GET", "/exporters", nil, nil)
+}
+
+// GetHealthz requests GET /healthz
+//
+// Liveness check
+func (c *Client) GetHealthz(ctx context.Context) ([]byte, error) {
+	return c.doRaw(ctx, "GET", "/healthz", nil, nil)
+}
+
+// ListMaps requests GET /maps
+//
+// Maps available in gamedirs
+func (c *Client) ListMaps(ctx context.Context) (MapsList, error) {
+	var result MapsList
+	err := c.do(ctx, "GET", "/maps", nil, nil, &result)
+	return result, err
+}
+
+// GetMetricsParams are query parameters of GetMetrics
+type GetMetricsParams struct {
+	// Target is server name
+	Target string
+}
+
+// GetMetrics requests GET /metrics
+//
+// Prometheus metrics of server, admin only: served by admin listener and not by public one
+func (c *Client) GetMetrics(ctx context.Context, params *GetMetricsParams) ([]byte, error) {
+	query := make(url.Values)
+	if params != nil {
+		if params.Target != "" {
+			query.Set("target", params.Target)
+		}
+	}
+	return c.doRaw(ctx, "GET", "/metrics", query, nil)
+}
+
+// GetOpenAPI requests GET /openapi.json
+//
+// This specification
+func (c *Client) GetOpenAPI(ctx context.Context) (OpenAPI, error) {
+	var result OpenAPI
+	err := c.do(ctx, "GET", "/openapi.json", nil, nil, &result)
+	return result, err
+}
+
+// GetRecordsParams are query parameters of GetRecords
+type GetRecordsParams struct {
+	// Gametype is gametype of records
+	Gametype string
+	// Map is map name prefix
+	Map string
+	// Holder is case insensitive substring of record holder name without colors
+	Holder string
+	// Sort is sort order
+	Sort string
+	// Format is response format
+	Format string
+	// Limit is page size, 0 means without limit
+	Limit *int64
+	// Offset is page offset
+	Offset *int64
+}
+
+// GetRecords requests GET /records
+//
+// Best records of maps available on servers
+func (c *Client) GetRecords(ctx context.Context, params *GetRecordsParams) (json.RawMessage, error) {
+	query := make(url.Values)
+	if params != nil {
+		if params.Gametype != "" {
+			query.Set("gametype", params.Gametype)
+		}
+		if params.Map != "" {
+			query.Set("map", params.Map)
+		}
+		if params.Holder != "" {
+			query.Set("holder", params.Holder)
+		}
+		if params.Sort != "" {
+			query.Set("sort", params.Sort)
+		}
+		if params.Format != "" {
+			query.Set("format", params.Format)
+		}
+		if params.Limit != nil {
+			query.Set("limit", strconv.FormatInt(*params.Limit, 10))
+		}
+		if params.Offset != nil {
+			query.Set("offset", strconv.FormatInt(*params.Offset, 10))
+		}
+	}
+	var result json.RawMessage
+	err := c.do(ctx, "GET", "/records", query, nil, &result)
+	return result, err
+}
+
+// GetRecordsFeedParams are query parameters of GetRecordsFeed
+type GetRecordsFeedParams struct {
+	// Format is feed format
+	Format string
+}
+
+// GetRecordsFeed requests GET /records/feed
+//
+// Recently broken ctf records
+func (c *Client) GetRecordsFeed(ctx context.Context, params *GetRecordsFeedParams) (*RecordsFeed, error) {
+	query := make(url.Values)
+	if params != nil {
+		if params.Format != "" {
+			query.Set("format", params.Format)
+		}
+	}
+	var result RecordsFeed
+	if err := c.do(ctx, "GET", "/records/feed", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListServersParams are query parameters of ListServers
+type ListServersParams struct {
+	// Expand is comma separated parts of servers to query: status, info and scores. Servers are queried concurrently and failed servers are marked offline
+	Expand string
+}
+
+// ListServers requests GET /servers
+//
+// Listed servers with display metadata, with expand details of all servers
+func (c *Client) ListServers(ctx context.Context, params *ListServersParams) (*ServersList, error) {
+	query := make(url.Values)
+	if params != nil {
+		if params.Expand != "" {
+			query.Set("expand", params.Expand)
+		}
+	}
+	var result ServersList
+	if err := c.do(ctx, "GET", "/servers", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetServer requests GET /servers/{server}
+//
+// Status, info and scores of server
+func (c *Client) GetServer(ctx context.Context, server string) (*ServerAll, error) {
+	var result ServerAll
+	if err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SendServerChat requests POST /servers/{server}/chat
+//
+// Send chat message to server
+func (c *Client) SendServerChat(ctx context.Context, server string, body *OutgoingChatMessage) error {
+	return c.do(ctx, "POST", "/servers/"+url.PathEscape(server)+"/chat", nil, body, nil)
+}
+
+// GetServerCvarsParams are query parameters of GetServerCvars
+type GetServerCvarsParams struct {
+	// Name is cvar names
+	Name []string
+}
+
+// GetServerCvars requests GET /servers/{server}/cvars
+//
+// Values of allowed cvars
+func (c *Client) GetServerCvars(ctx context.Context, server string, params *GetServerCvarsParams) (*CvarsList, error) {
+	query := make(url.Values)
+	if params != nil {
+		for _, value := range params.Name {
+			query.Add("name", value)
+		}
+	}
+	var result CvarsList
+	if err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server)+"/cvars", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetServerInfoParams are query parameters of GetServerInfo
+type GetServerInfoParams struct {
+	// Version is response version
+	Version string
+}
+
+// GetServerInfo requests GET /servers/{server}/info
+//
+// Server info
+func (c *Client) GetServerInfo(ctx context.Context, server string, params *GetServerInfoParams) (json.RawMessage, error) {
+	query := make(url.Values)
+	if params != nil {
+		if params.Version != "" {
+			query.Set("version", params.Version)
+		}
+	}
+	var result json.RawMessage
+	err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server)+"/info", query, nil, &result)
+	return result, err
+}
+
+// GetServerPopulationParams are query parameters of GetServerPopulation
+type GetServerPopulationParams struct {
+	// From is start of range as unix time or RFC3339, 24 hours ago by default
+	From string
+	// To is end of range as unix time or RFC3339, now by default
+	To string
+	// Step is duration between points of at least 1m like 5m or 1h
+	Step string
+	// Format is response format
+	Format string
+}
+
+// GetServerPopulation requests GET /servers/{server}/population
+//
+// Players count history
+func (c *Client) GetServerPopulation(ctx context.Context, server string, params *GetServerPopulationParams) (*Population, error) {
+	query := make(url.Values)
+	if params != nil {
+		if params.From != "" {
+			query.Set("from", params.From)
+		}
+		if params.To != "" {
+			query.Set("to", params.To)
+		}
+		if params.Step != "" {
+			query.Set("step", params.Step)
+		}
+		if params.Format != "" {
+			query.Set("format", params.Format)
+		}
+	}
+	var result Population
+	if err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server)+"/population", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetServerPopulationHeatmapParams are query parameters of GetServerPopulationHeatmap
+type GetServerPopulationHeatmapParams struct {
+	// Tz is IANA time zone name
+	Tz string
+	// Weeks is number of weeks, 4 by default
+	Weeks *int64
+}
+
+// GetServerPopulationHeatmap requests GET /servers/{server}/population/heatmap
+//
+// Average players count by weekday and hour
+func (c *Client) GetServerPopulationHeatmap(ctx context.Context, server string, params *GetServerPopulationHeatmapParams) (*PopulationHeatmap, error) {
+	query := make(url.Values)
+	if params != nil {
+		if params.Tz != "" {
+			query.Set("tz", params.Tz)
+		}
+		if params.Weeks != nil {
+			query.Set("weeks", strconv.FormatInt(*params.Weeks, 10))
+		}
+	}
+	var result PopulationHeatmap
+	if err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server)+"/population/heatmap", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetServerRotation requests GET /servers/{server}/rotation
+//
+// Map rotation
+func (c *Client) GetServerRotation(ctx context.Context, server string) (*Rotation, error) {
+	var result Rotation
+	if err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server)+"/rotation", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateServerRotation requests PUT /servers/{server}/rotation
+//
+// Change map rotation
+func (c *Client) UpdateServerRotation(ctx context.Context, server string, body *RotationUpdate) (*Rotation, error) {
+	var result Rotation
+	if err := c.do(ctx, "PUT", "/servers/"+url.PathEscape(server)+"/rotation", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetServerScores requests GET /servers/{server}/scores
+//
+// Scores of current match
+func (c *Client) GetServerScores(ctx context.Context, server string) (*ServerScores, error) {
+	var result ServerScores
+	if err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server)+"/scores", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetServerStatus requests GET /servers/{server}/status
+//
+// Server status with players
+func (c *Client) GetServerStatus(ctx context.Context, server string) (*ServerStatus, error) {
+	var result ServerStatus
+	if err := c.do(ctx, "GET", "/servers/"+url.PathEscape(server)+"/status", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListSubscriptions requests GET /subscriptions
+//
+// Subscriptions of token owner
+func (c *Client) ListSubscriptions(ctx context.Context) (*SubscriptionsList, error) {
+	var result SubscriptionsList
+	if err := c.do(ctx, "GET", "/subscriptions", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateSubscription requests POST /subscriptions
+//
+// Subscribe to server population notifications
+func (c *Client) CreateSubscription(ctx context.Context, body *Subscription) (*SubscriptionCreated, error) {
+	var result SubscriptionCreated
+	if err := c.do(ctx, "POST", "/subscriptions", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetVapidKey requests GET /subscriptions/vapid-key
+//
+// Public VAPID key for web push
+func (c *Client) GetVapidKey(ctx context.Context) (*VapidKey, error) {
+	var result VapidKey
+	if err := c.do(ctx, "GET", "/subscriptions/vapid-key", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteSubscription requests DELETE /subscriptions/{id}
+//
+// Unsubscribe
+func (c *Client) DeleteSubscription(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/subscriptions/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/servers/regulars ctf":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"host":"Regulars","map":"bloodrage","players_count":1,` +
+				`"timing":{"cpu":1.5},"info":{"gametype":"ctf"},"scores":null}`))
+		case "/servers/regulars ctf/cvars":
+			if r.Header.Get("Authorization") != "Bearer secret" || len(r.URL.Query()["name"]) != 2 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"cvars":{"g_ctf":{"name":"g_ctf","value":"1","default":"0"}}}`))
+		case "/servers/missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"not_found","message":"Server not found"}}`))
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := New(server.URL + "/")
+	ctx := context.Background()
+
+	all, err := client.GetServer(ctx, "regulars ctf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Host != "Regulars" || all.Timing.CPU != 1.5 || all.Info.Gametype != "ctf" || all.Scores != nil {
+		t.Error("Incorrectly decoded server ", all)
+	}
+
+	_, err = client.GetServerCvars(ctx, "regulars ctf", &GetServerCvarsParams{Name: []string{"g_ctf", "g_cts"}})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "unauthorized" || apiErr.Message != "Unauthorized" {
+		t.Error("Expected unauthorized error ", err)
+	}
+	client.Token = "secret"
+	cvars, err := client.GetServerCvars(ctx, "regulars ctf", &GetServerCvarsParams{Name: []string{"g_ctf", "g_cts"}})
+	if err != nil || cvars.Cvars["g_ctf"].Value != "1" {
+		t.Error("Incorrect cvars ", cvars, err)
+	}
+
+	_, err = client.GetServer(ctx, "missing")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Server not found" {
+		t.Error("Expected not found error ", err)
+	}
+}
@@ -0,0 +1,279 @@
+package gamedb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBuckets is DB_BUCKETS from xonotic db.qc
+const DefaultBuckets = 8192
+
+const hexDigits = "0123456789ABCDEF"
+
+type entry struct {
+	key string
+	// value is kept escaped, so unchanged entries are written back as is
+	raw string
+}
+
+// DB is in-memory copy of darkplaces server.db, each line of the file is
+// hash bucket with \key\value pairs and values are uri escaped
+type DB struct {
+	Buckets int
+	lines   [][]entry
+}
+
+func New() *DB {
+	return &DB{Buckets: DefaultBuckets}
+}
+
+var crcTable [256]uint16
+
+func init() {
+	// CRC-CCITT table, same as darkplaces crc.c
+	for i := range crcTable {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		crcTable[i] = crc
+	}
+}
+
+// crc16 is CRC_Block from darkplaces which is used by crc16() builtin
+func crc16(data string) uint16 {
+	crc := uint16(0xffff)
+	for i := 0; i < len(data); i++ {
+		crc = crc<<8 ^ crcTable[byte(crc>>8)^data[i]]
+	}
+	return crc
+}
+
+func (db *DB) bucket(key string) int {
+	return int(crc16(key)) % db.Buckets
+}
+
+// Escape is uri_escape builtin from darkplaces
+func Escape(value string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			strings.IndexByte("-_.!~'()", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xF])
+		}
+	}
+	return b.String()
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// Unescape is uri_unescape builtin from darkplaces, invalid escapes are kept as is
+func Unescape(raw string) string {
+	return string(unescapeInPlace([]byte(raw)))
+}
+
+// Read parses database, like db_load in xonotic database with other
+// buckets count or without header is rehashed
+func Read(r io.Reader) (*DB, error) {
+	db := New()
+	tokenizer := NewTokenizer(r)
+	for {
+		key, raw, err := tokenizer.NextRaw()
+		if err == io.EOF {
+			return db, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if tokenizer.Buckets != DefaultBuckets || tokenizer.Version != 0 {
+			db.putRaw(string(key), string(raw))
+			continue
+		}
+		// line after header is first bucket
+		bucket := tokenizer.Line() - 2
+		for len(db.lines) <= bucket {
+			db.lines = append(db.lines, nil)
+		}
+		db.lines[bucket] = append(db.lines[bucket], entry{key: string(key), raw: string(raw)})
+	}
+}
+
+func ReadFile(path string) (*DB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	db, err := Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// WriteTo writes database in format of db_save from xonotic
+func (db *DB) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	writer := bufio.NewWriter(w)
+	n, err := fmt.Fprintf(writer, "%d\n", db.Buckets)
+	total += int64(n)
+	if err != nil {
+		return total, err
+	}
+	for _, entries := range db.lines {
+		for _, e := range entries {
+			n, err = fmt.Fprintf(writer, "\\%s\\%s", e.key, e.raw)
+			total += int64(n)
+			if err != nil {
+				return total, err
+			}
+		}
+		if err = writer.WriteByte('\n'); err != nil {
+			return total, err
+		}
+		total++
+	}
+	return total, writer.Flush()
+}
+
+// WriteFile writes database to temporary file and renames it over path
+func (db *DB) WriteFile(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = db.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (db *DB) Get(key string) (string, bool) {
+	bucket := db.bucket(key)
+	if bucket >= len(db.lines) {
+		return "", false
+	}
+	for _, e := range db.lines[bucket] {
+		if e.key == key {
+			return Unescape(e.raw), true
+		}
+	}
+	return "", false
+}
+
+func (db *DB) putRaw(key, raw string) {
+	bucket := db.bucket(key)
+	for len(db.lines) <= bucket {
+		db.lines = append(db.lines, nil)
+	}
+	for i, e := range db.lines[bucket] {
+		if e.key == key {
+			db.lines[bucket][i].raw = raw
+			return
+		}
+	}
+	db.lines[bucket] = append(db.lines[bucket], entry{key: key, raw: raw})
+}
+
+// Put sets value of key, keys can't contain backslashes or new lines
+func (db *DB) Put(key, value string) {
+	if strings.ContainsAny(key, "\\\n") {
+		panic(fmt.Sprintf("invalid gamedb key %q", key))
+	}
+	db.putRaw(key, Escape(value))
+}
+
+func (db *DB) Delete(key string) bool {
+	bucket := db.bucket(key)
+	if bucket >= len(db.lines) {
+		return false
+	}
+	for i, e := range db.lines[bucket] {
+		if e.key == key {
+			db.lines[bucket] = append(db.lines[bucket][:i], db.lines[bucket][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Range calls fn for all entries in file order until fn returns false,
+// database shouldn't be modified by fn
+func (db *DB) Range(fn func(key, value string) bool) {
+	for _, entries := range db.lines {
+		for _, e := range entries {
+			if !fn(e.key, Unescape(e.raw)) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns keys in file order
+func (db *DB) Keys() []string {
+	var keys []string
+
+	for _, entries := range db.lines {
+		for _, e := range entries {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// Verify returns problems which make entries unreachable for game server
+func (db *DB) Verify() []error {
+	var problems []error
+
+	seen := make(map[string]bool)
+	for bucket, entries := range db.lines {
+		for _, e := range entries {
+			if seen[e.key] {
+				problems = append(problems, fmt.Errorf("duplicate key %q", e.key))
+			}
+			seen[e.key] = true
+			if expected := db.bucket(e.key); expected != bucket {
+				problems = append(problems, fmt.Errorf("key %q is in bucket %d instead of %d", e.key, bucket, expected))
+			}
+		}
+	}
+	return problems
+}
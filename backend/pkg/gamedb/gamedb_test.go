@@ -0,0 +1,184 @@
+package gamedb
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func buildDB(entries map[string]string) *DB {
+	db := New()
+	for k, v := range entries {
+		db.Put(k, v)
+	}
+	return db
+}
+
+func TestCrc16(t *testing.T) {
+	// CRC-16/CCITT-FALSE check value
+	if crc := crc16("123456789"); crc != 0x29B1 {
+		t.Errorf("Incorrect crc16 %#x", crc)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	value := "^1Player \\ 100% ok"
+	escaped := Escape(value)
+	if escaped != "%5E1Player%20%5C%20100%25%20ok" {
+		t.Error("Incorrect escaped value ", escaped)
+	}
+	if Unescape(escaped) != value {
+		t.Error("Incorrect unescaped value ", Unescape(escaped))
+	}
+	if Unescape("100%zz%4") != "100%zz%4" {
+		t.Error("Invalid escapes should be kept")
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	db := buildDB(map[string]string{
+		"afterslime/captimerecord/time":    "12.5",
+		"afterslime/captimerecord/netname": "^1Red",
+		"/uid2name/abc=":                   "Player",
+	})
+	var buf bytes.Buffer
+	if _, err := db.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.String()
+	if !strings.HasPrefix(data, "8192\n") {
+		t.Error("Missing buckets header")
+	}
+	read, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := read.Get("afterslime/captimerecord/netname"); value != "^1Red" {
+		t.Error("Incorrect value ", value)
+	}
+	buf.Reset()
+	read.WriteTo(&buf)
+	if buf.String() != data {
+		t.Error("Database wasn't written back unchanged")
+	}
+	if problems := read.Verify(); len(problems) != 0 {
+		t.Error("Unexpected problems ", problems)
+	}
+}
+
+func TestReadRehash(t *testing.T) {
+	// dump without buckets header
+	db, err := Read(strings.NewReader("\\a/captimerecord/time\\10\\b/captimerecord/time\\20\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := db.Get("b/captimerecord/time"); !ok || value != "20" {
+		t.Error("Key wasn't rehashed ", value)
+	}
+	if problems := db.Verify(); len(problems) != 0 {
+		t.Error("Unexpected problems ", problems)
+	}
+	if _, err := Read(strings.NewReader("8192\n\\key\n")); err == nil {
+		t.Error("Key without value should fail")
+	}
+}
+
+func TestRemovePlayer(t *testing.T) {
+	db := buildDB(map[string]string{
+		"map/cts100record/time1":        "1000",
+		"map/cts100record/netname1":     "First",
+		"map/cts100record/crypto_idfp1": "cheater",
+		"map/cts100record/time2":        "1100",
+		"map/cts100record/netname2":     "Second",
+		"map/cts100record/crypto_idfp2": "second",
+		"map/cts100record/time3":        "1200",
+		"map/cts100record/netname3":     "Third",
+		"map/captimerecord/time":        "10.5",
+		"map/captimerecord/netname":     "First",
+		"map/captimerecord/crypto_idfp": "cheater",
+		"/uid2name/cheater":             "First",
+	})
+	if removed := db.RemovePlayer("cheater"); removed != 2 {
+		t.Error("Incorrect number of removed records ", removed)
+	}
+	rankings := db.Rankings("map", "cts100record")
+	if len(rankings) != 2 || rankings[0].Pos != 1 || rankings[0].Name != "Second" ||
+		rankings[1].Pos != 2 || rankings[1].Name != "Third" {
+		t.Error("Rankings weren't renumbered ", rankings)
+	}
+	if _, ok := db.Get("map/captimerecord/time"); ok {
+		t.Error("Captime record wasn't removed")
+	}
+	if _, ok := db.Get("/uid2name/cheater"); ok {
+		t.Error("Player name wasn't removed")
+	}
+	if problems := db.VerifyRecords(); len(problems) != 0 {
+		t.Error("Unexpected problems ", problems)
+	}
+}
+
+func TestDeleteMap(t *testing.T) {
+	db := buildDB(map[string]string{
+		"map/captimerecord/time":  "10",
+		"map2/captimerecord/time": "10",
+	})
+	if removed := db.DeleteMap("map"); removed != 1 {
+		t.Error("Incorrect number of removed keys ", removed)
+	}
+	if _, ok := db.Get("map2/captimerecord/time"); !ok {
+		t.Error("Other map was removed")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	db := buildDB(map[string]string{
+		"map/cts100record/time1":        "1000",
+		"map/cts100record/netname1":     "A",
+		"map/cts100record/crypto_idfp1": "a",
+		"map/cts100record/time2":        "1500",
+		"map/cts100record/netname2":     "B",
+		"map/cts100record/crypto_idfp2": "b",
+		"map/captimerecord/time":        "10",
+		"map/captimerecord/netname":     "A",
+		"/uid2name/a":                   "A",
+	})
+	other := buildDB(map[string]string{
+		"map/cts100record/time1":        "1200",
+		"map/cts100record/netname1":     "B",
+		"map/cts100record/crypto_idfp1": "b",
+		"map/captimerecord/time":        "9",
+		"map/captimerecord/netname":     "C",
+		"/uid2name/a":                   "Renamed",
+		"/uid2name/c":                   "C",
+	})
+	db.Merge(other)
+	rankings := db.Rankings("map", "cts100record")
+	if len(rankings) != 2 || rankings[1].Time != 1200 || rankings[1].IDFP != "b" {
+		t.Error("Incorrectly merged rankings ", rankings)
+	}
+	if name, _ := db.Get("map/captimerecord/netname"); name != "C" {
+		t.Error("Better captime record wasn't merged ", name)
+	}
+	if name, _ := db.Get("/uid2name/a"); name != "A" {
+		t.Error("Existing key was overwritten ", name)
+	}
+	if name, _ := db.Get("/uid2name/c"); name != "C" {
+		t.Error("Missing key wasn't copied ", name)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.db")
+	db := buildDB(map[string]string{"map/captimerecord/time": "10"})
+	if err := db.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := read.Get("map/captimerecord/time"); value != "10" {
+		t.Error("Incorrect value ", value)
+	}
+}
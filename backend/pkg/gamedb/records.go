@@ -0,0 +1,318 @@
+package gamedb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxRankings is RANKINGS_CNT from xonotic race.qh
+	MaxRankings    = 99
+	captimeRecord  = "captimerecord"
+	speedRecord    = "speed"
+	uid2namePrefix = "/uid2name/"
+)
+
+// RankingSets are prefixes of race and cts rankings in race.qc
+var RankingSets = []string{"cts100record", "race100record"}
+
+// Ranking is position in cts or race rankings, time is in centiseconds
+type Ranking struct {
+	Pos  int
+	Time int64
+	Name string
+	IDFP string
+}
+
+// parseRankingKey splits key like afterslime/cts100record/time3
+func parseRankingKey(key string) (mapname, set, field string, pos int, ok bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 {
+		return "", "", "", 0, false
+	}
+	for _, f := range []string{"time", "netname", "crypto_idfp"} {
+		if !strings.HasPrefix(parts[2], f) {
+			continue
+		}
+		pos, err := strconv.Atoi(strings.TrimPrefix(parts[2], f))
+		if err != nil || pos < 1 {
+			return "", "", "", 0, false
+		}
+		for _, s := range RankingSets {
+			if parts[1] == s {
+				return parts[0], s, f, pos, true
+			}
+		}
+	}
+	return "", "", "", 0, false
+}
+
+func rankingKey(mapname, set, field string, pos int) string {
+	return fmt.Sprintf("%s/%s/%s%d", mapname, set, field, pos)
+}
+
+// Rankings returns ranking entries of map sorted by position, positions can have gaps
+func (db *DB) Rankings(mapname, set string) []Ranking {
+	var result []Ranking
+
+	positions := make(map[int]bool)
+	db.Range(func(key, value string) bool {
+		m, s, _, pos, ok := parseRankingKey(key)
+		if ok && m == mapname && s == set {
+			positions[pos] = true
+		}
+		return true
+	})
+	for pos := range positions {
+		r := Ranking{Pos: pos}
+		value, _ := db.Get(rankingKey(mapname, set, "time", pos))
+		r.Time, _ = strconv.ParseInt(value, 10, 64)
+		r.Name, _ = db.Get(rankingKey(mapname, set, "netname", pos))
+		r.IDFP, _ = db.Get(rankingKey(mapname, set, "crypto_idfp", pos))
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Pos < result[j].Pos
+	})
+	return result
+}
+
+// setRankings replaces ranking of map with entries numbered from 1
+func (db *DB) setRankings(mapname, set string, rankings []Ranking) {
+	for _, r := range db.Rankings(mapname, set) {
+		db.deleteRanking(mapname, set, r.Pos)
+	}
+	for i, r := range rankings {
+		pos := i + 1
+		db.Put(rankingKey(mapname, set, "time", pos), strconv.FormatInt(r.Time, 10))
+		db.Put(rankingKey(mapname, set, "netname", pos), r.Name)
+		if r.IDFP != "" {
+			db.Put(rankingKey(mapname, set, "crypto_idfp", pos), r.IDFP)
+		}
+	}
+}
+
+func (db *DB) deleteRanking(mapname, set string, pos int) {
+	for _, field := range []string{"time", "netname", "crypto_idfp"} {
+		db.Delete(rankingKey(mapname, set, field, pos))
+	}
+}
+
+// RankedMaps returns maps with rankings of set
+func (db *DB) RankedMaps(set string) []string {
+	var maps []string
+
+	seen := make(map[string]bool)
+	db.Range(func(key, value string) bool {
+		m, s, _, _, ok := parseRankingKey(key)
+		if ok && s == set && !seen[m] {
+			seen[m] = true
+			maps = append(maps, m)
+		}
+		return true
+	})
+	sort.Strings(maps)
+	return maps
+}
+
+// Renumber sorts rankings of map by time and closes gaps in positions,
+// it returns true when positions were changed
+func (db *DB) Renumber(mapname string) bool {
+	changed := false
+	for _, set := range RankingSets {
+		rankings := db.Rankings(mapname, set)
+		sort.SliceStable(rankings, func(i, j int) bool {
+			return rankings[i].Time < rankings[j].Time
+		})
+		needed := false
+		for i, r := range rankings {
+			if r.Pos != i+1 {
+				needed = true
+			}
+		}
+		if needed {
+			db.setRankings(mapname, set, rankings)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// DeleteMap removes all records of map and returns number of removed keys
+func (db *DB) DeleteMap(mapname string) int {
+	var keys []string
+
+	prefix := mapname + "/"
+	for _, key := range db.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		db.Delete(key)
+	}
+	return len(keys)
+}
+
+// RemovePlayer removes records of player and renumbers affected rankings,
+// it returns number of removed records
+func (db *DB) RemovePlayer(idfp string) int {
+	removed := 0
+	affected := make(map[string]bool)
+	var recordMaps []string
+	var speedMaps []string
+	for _, key := range db.Keys() {
+		value, _ := db.Get(key)
+		if value != idfp {
+			continue
+		}
+		if m, s, field, pos, ok := parseRankingKey(key); ok && field == "crypto_idfp" {
+			db.deleteRanking(m, s, pos)
+			affected[m] = true
+			removed++
+		} else if strings.HasSuffix(key, "/"+captimeRecord+"/crypto_idfp") {
+			recordMaps = append(recordMaps, strings.TrimSuffix(key, "/"+captimeRecord+"/crypto_idfp"))
+		} else if strings.HasSuffix(key, "/"+speedRecord+"/crypto_idfp") {
+			speedMaps = append(speedMaps, strings.TrimSuffix(key, "/"+speedRecord+"/crypto_idfp"))
+		}
+	}
+	for _, prefix := range recordMaps {
+		for _, field := range []string{"time", "netname", "crypto_idfp"} {
+			db.Delete(prefix + "/" + captimeRecord + "/" + field)
+		}
+		removed++
+	}
+	for _, prefix := range speedMaps {
+		for _, field := range []string{"speed", "netname", "crypto_idfp"} {
+			db.Delete(prefix + "/" + speedRecord + "/" + field)
+		}
+		removed++
+	}
+	db.Delete(uid2namePrefix + idfp)
+	for m := range affected {
+		db.Renumber(m)
+	}
+	return removed
+}
+
+// mergeRankings combines rankings keeping best time of each player
+func mergeRankings(a, b []Ranking) []Ranking {
+	var result []Ranking
+
+	best := make(map[string]int)
+	for _, r := range append(append([]Ranking{}, a...), b...) {
+		if r.IDFP == "" {
+			result = append(result, r)
+			continue
+		}
+		if i, ok := best[r.IDFP]; ok {
+			if r.Time < result[i].Time {
+				result[i] = r
+			}
+			continue
+		}
+		best[r.IDFP] = len(result)
+		result = append(result, r)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time < result[j].Time
+	})
+	if len(result) > MaxRankings {
+		result = result[:MaxRankings]
+	}
+	return result
+}
+
+func parseFloat(value string) (float64, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	return f, err == nil
+}
+
+// Merge copies records from other database, best times win and other keys
+// are copied only when they are missing
+func (db *DB) Merge(other *DB) {
+	for _, set := range RankingSets {
+		for _, m := range other.RankedMaps(set) {
+			db.setRankings(m, set, mergeRankings(db.Rankings(m, set), other.Rankings(m, set)))
+		}
+	}
+	other.Range(func(key, value string) bool {
+		if _, _, _, _, ok := parseRankingKey(key); ok {
+			return true
+		}
+		current, exists := db.Get(key)
+		switch {
+		case strings.HasSuffix(key, "/"+captimeRecord+"/time"):
+			prefix := strings.TrimSuffix(key, "time")
+			t, ok := parseFloat(value)
+			currentTime, currentOk := parseFloat(current)
+			if ok && (!exists || !currentOk || t < currentTime) {
+				for _, field := range []string{"time", "netname", "crypto_idfp"} {
+					db.Delete(prefix + field)
+					if v, ok := other.Get(prefix + field); ok {
+						db.Put(prefix+field, v)
+					}
+				}
+			}
+		case strings.HasSuffix(key, "/"+speedRecord+"/speed"):
+			prefix := strings.TrimSuffix(key, "speed")
+			speed, ok := parseFloat(value)
+			currentSpeed, currentOk := parseFloat(current)
+			if ok && (!exists || !currentOk || speed > currentSpeed) {
+				for _, field := range []string{"speed", "netname", "crypto_idfp"} {
+					db.Delete(prefix + field)
+					if v, ok := other.Get(prefix + field); ok {
+						db.Put(prefix+field, v)
+					}
+				}
+			}
+		case strings.HasSuffix(key, "/"+captimeRecord+"/netname"),
+			strings.HasSuffix(key, "/"+captimeRecord+"/crypto_idfp"),
+			strings.HasSuffix(key, "/"+speedRecord+"/netname"),
+			strings.HasSuffix(key, "/"+speedRecord+"/crypto_idfp"):
+			// copied together with time or speed
+		default:
+			if !exists {
+				db.Put(key, value)
+			}
+		}
+		return true
+	})
+}
+
+// VerifyRecords checks that rankings are numbered from 1 without gaps,
+// sorted by time and have times and names
+func (db *DB) VerifyRecords() []error {
+	var problems []error
+
+	for _, set := range RankingSets {
+		for _, m := range db.RankedMaps(set) {
+			var prev int64
+			for i, r := range db.Rankings(m, set) {
+				if r.Pos != i+1 {
+					problems = append(problems, fmt.Errorf("%s %s: position %d follows %d", m, set, r.Pos, i))
+				}
+				if r.Time <= 0 {
+					problems = append(problems, fmt.Errorf("%s %s: position %d has invalid time", m, set, r.Pos))
+				} else if r.Time < prev {
+					problems = append(problems, fmt.Errorf("%s %s: position %d is faster than previous", m, set, r.Pos))
+				}
+				if r.Name == "" {
+					problems = append(problems, fmt.Errorf("%s %s: position %d doesn't have name", m, set, r.Pos))
+				}
+				prev = r.Time
+			}
+		}
+	}
+	db.Range(func(key, value string) bool {
+		if strings.HasSuffix(key, "/"+captimeRecord+"/time") {
+			if t, ok := parseFloat(value); !ok || t <= 0 {
+				problems = append(problems, fmt.Errorf("%s: invalid captime %q", key, value))
+			}
+		}
+		return true
+	})
+	return problems
+}
@@ -0,0 +1,173 @@
+package gamedb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SyntaxError describes malformed database line, offset is counted
+// in bytes from start of the input
+type SyntaxError struct {
+	Line   int
+	Offset int64
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, offset %d: %s", e.Line, e.Offset, e.Msg)
+}
+
+// Tokenizer reads \key\value pairs from database without loading it into memory,
+// slices returned by Next are valid only until next call
+type Tokenizer struct {
+	reader *bufio.Reader
+	// current line, it's reused between lines
+	buf        []byte
+	pos        int
+	line       int
+	lineOffset int64
+	nextOffset int64
+	// Buckets is buckets count from header, it's 0 when header is missing
+	Buckets int
+	// Version is database version from dbver header
+	Version int
+}
+
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Line returns number of line where last pair was read
+func (t *Tokenizer) Line() int {
+	return t.line
+}
+
+func (t *Tokenizer) syntaxError(pos int, msg string) error {
+	return &SyntaxError{Line: t.line, Offset: t.lineOffset + int64(pos), Msg: msg}
+}
+
+// readLine reads next line into buf without new line character
+func (t *Tokenizer) readLine() error {
+	t.buf = t.buf[:0]
+	for {
+		chunk, err := t.reader.ReadSlice('\n')
+		t.buf = append(t.buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(t.buf) > 0 {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+	t.line++
+	t.lineOffset = t.nextOffset
+	t.nextOffset += int64(len(t.buf))
+	t.buf = bytes.TrimSuffix(t.buf, []byte("\n"))
+	t.pos = 0
+	return nil
+}
+
+// parseHeader handles buckets count and dbver header lines
+func (t *Tokenizer) parseHeader() error {
+	fields := bytes.Fields(t.buf)
+	if len(fields) == 2 && string(fields[0]) == "dbver" {
+		version, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return t.syntaxError(0, "invalid dbver header")
+		}
+		t.Version = version
+		return nil
+	}
+	if len(fields) == 1 {
+		buckets, err := strconv.Atoi(string(fields[0]))
+		if err != nil || buckets <= 0 {
+			return t.syntaxError(0, "invalid buckets count")
+		}
+		t.Buckets = buckets
+		return nil
+	}
+	return t.syntaxError(0, "line should start with \\")
+}
+
+// NextRaw returns next key and value without unescaping
+func (t *Tokenizer) NextRaw() (key, value []byte, err error) {
+	for t.pos >= len(t.buf) {
+		if err := t.readLine(); err != nil {
+			return nil, nil, err
+		}
+		t.buf = bytes.TrimSuffix(t.buf, []byte("\r"))
+		if len(t.buf) == 0 {
+			continue
+		}
+		if t.buf[0] != '\\' {
+			// headers are allowed only before pairs
+			if t.line > 2 || (t.line == 2 && t.Buckets == 0 && t.Version == 0) {
+				return nil, nil, t.syntaxError(0, "line should start with \\")
+			}
+			if err := t.parseHeader(); err != nil {
+				return nil, nil, err
+			}
+			t.pos = len(t.buf)
+		}
+	}
+	// buf[pos] is separator before key
+	keyStart := t.pos + 1
+	keyEnd := bytes.IndexByte(t.buf[keyStart:], '\\')
+	if keyEnd < 0 {
+		return nil, nil, t.syntaxError(keyStart, fmt.Sprintf("key %q doesn't have value", t.buf[keyStart:]))
+	}
+	keyEnd += keyStart
+	valueStart := keyEnd + 1
+	valueEnd := bytes.IndexByte(t.buf[valueStart:], '\\')
+	if valueEnd < 0 {
+		valueEnd = len(t.buf)
+	} else {
+		valueEnd += valueStart
+	}
+	t.pos = valueEnd
+	return t.buf[keyStart:keyEnd], t.buf[valueStart:valueEnd], nil
+}
+
+// SkipLine drops rest of current line, reading can continue after SyntaxError with next line
+func (t *Tokenizer) SkipLine() {
+	t.pos = len(t.buf)
+}
+
+// Next returns next key and unescaped value, io.EOF is returned at the end
+func (t *Tokenizer) Next() (key, value []byte, err error) {
+	key, value, err = t.NextRaw()
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, unescapeInPlace(value), nil
+}
+
+// unescapeInPlace is uri_unescape which reuses memory of value
+func unescapeInPlace(value []byte) []byte {
+	if bytes.IndexByte(value, '%') < 0 {
+		return value
+	}
+	n := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) {
+			hi, ok1 := unhex(value[i+1])
+			lo, ok2 := unhex(value[i+2])
+			if ok1 && ok2 {
+				value[n] = hi<<4 | lo
+				n++
+				i += 2
+				continue
+			}
+		}
+		value[n] = value[i]
+		n++
+	}
+	return value[:n]
+}
@@ -0,0 +1,197 @@
+package gamedb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const sampleDB = "8192\n" +
+	"\\afterslime/captimerecord/time\\12.5\\afterslime/captimerecord/netname\\%5E1Red%20Player\n" +
+	"\n" +
+	"\\/uid2name/abc%3D\\Bad%zzEscape%4\\empty\\\n"
+
+type pair struct {
+	key, value string
+}
+
+func tokenize(in string) ([]pair, *Tokenizer, error) {
+	var pairs []pair
+
+	tokenizer := NewTokenizer(strings.NewReader(in))
+	for {
+		key, value, err := tokenizer.Next()
+		if err == io.EOF {
+			return pairs, tokenizer, nil
+		} else if err != nil {
+			return pairs, tokenizer, err
+		}
+		pairs = append(pairs, pair{string(key), string(value)})
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	pairs, tokenizer, err := tokenize(sampleDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pair{
+		{"afterslime/captimerecord/time", "12.5"},
+		{"afterslime/captimerecord/netname", "^1Red Player"},
+		{"/uid2name/abc%3D", "Bad%zzEscape%4"},
+		{"empty", ""},
+	}
+	if fmt.Sprint(pairs) != fmt.Sprint(expected) {
+		t.Errorf("Incorrect pairs %q", pairs)
+	}
+	if tokenizer.Buckets != DefaultBuckets || tokenizer.Line() != 4 {
+		t.Error("Incorrect tokenizer state ", tokenizer.Buckets, tokenizer.Line())
+	}
+}
+
+func TestTokenizerHeaders(t *testing.T) {
+	_, tokenizer, err := tokenize("dbver 2\n8192\n\\key\\value\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenizer.Version != 2 || tokenizer.Buckets != DefaultBuckets {
+		t.Error("Headers weren't parsed ", tokenizer.Version, tokenizer.Buckets)
+	}
+	// dump without header
+	pairs, _, err := tokenize("\\key\\value\r\n")
+	if err != nil || len(pairs) != 1 || pairs[0].value != "value" {
+		t.Error("Incorrectly parsed dump ", pairs, err)
+	}
+}
+
+func TestTokenizerErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		line   int
+		offset int64
+	}{
+		{"8192\n\\key\\value\\broken\n", 2, 16},
+		{"8192\n\\key\\value\ngarbage\n", 3, 16},
+		{"nonsense header\n", 1, 0},
+		{"dbver x\n", 1, 0},
+	}
+	for _, test := range tests {
+		var syntaxErr *SyntaxError
+
+		_, _, err := tokenize(test.in)
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Expected syntax error for %q, got %v", test.in, err)
+			continue
+		}
+		if syntaxErr.Line != test.line || syntaxErr.Offset != test.offset {
+			t.Errorf("Incorrect error position for %q: %v", test.in, syntaxErr)
+		}
+	}
+}
+
+func TestTokenizerSkipLine(t *testing.T) {
+	var keys []string
+
+	tokenizer := NewTokenizer(strings.NewReader("8192\n\\a\\1\\broken\ngarbage\n\\b\\2\n"))
+	for {
+		key, _, err := tokenizer.Next()
+		if err == io.EOF {
+			break
+		}
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			tokenizer.SkipLine()
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, string(key))
+	}
+	if strings.Join(keys, ",") != "a,b" {
+		t.Error("Reading should continue after broken lines ", keys)
+	}
+}
+
+func TestTokenizerLongLine(t *testing.T) {
+	value := strings.Repeat("x", 200*1024)
+	pairs, _, err := tokenize("8192\n\\key\\" + value + "\\key2\\v\n")
+	if err != nil || len(pairs) != 2 || pairs[0].value != value {
+		t.Error("Long line wasn't parsed ", err)
+	}
+}
+
+func FuzzTokenizer(f *testing.F) {
+	f.Add(sampleDB)
+	f.Add("dbver 1\n8192\n\\a\\b\n")
+
+	f.Fuzz(func(t *testing.T, in string) {
+		pairs, _, err := tokenize(in)
+		if err != nil {
+			return
+		}
+		// escaped pairs should be read back unchanged
+		var buf bytes.Buffer
+		for _, p := range pairs {
+			if strings.ContainsAny(p.key, "\\\n") {
+				t.Fatalf("Invalid key %q", p.key)
+			}
+			fmt.Fprintf(&buf, "\\%s\\%s\n", p.key, Escape(p.value))
+		}
+		again, _, err := tokenize(buf.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(again) != len(pairs) {
+			t.Fatalf("Pairs count changed %q %q", pairs, again)
+		}
+		for i := range pairs {
+			if pairs[i].value != again[i].value {
+				t.Fatalf("Value changed %q %q", pairs[i].value, again[i].value)
+			}
+		}
+	})
+}
+
+func benchmarkDB(maps int) string {
+	db := New()
+	for i := 0; i < maps; i++ {
+		mapname := fmt.Sprintf("map%d", i)
+		db.Put(mapname+"/captimerecord/time", fmt.Sprintf("%d.25", 10+i))
+		db.Put(mapname+"/captimerecord/netname", fmt.Sprintf("^1Player %d^7", i))
+		for pos := 1; pos <= 10; pos++ {
+			db.Put(fmt.Sprintf("%s/cts100record/time%d", mapname, pos), fmt.Sprint(1000*pos))
+			db.Put(fmt.Sprintf("%s/cts100record/netname%d", mapname, pos), "Some Player")
+		}
+	}
+	var buf bytes.Buffer
+	db.WriteTo(&buf)
+	return buf.String()
+}
+
+func BenchmarkTokenizer(b *testing.B) {
+	data := benchmarkDB(500)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer := NewTokenizer(strings.NewReader(data))
+		for {
+			if _, _, err := tokenizer.Next(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	data := benchmarkDB(500)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Read(strings.NewReader(data))
+	}
+}
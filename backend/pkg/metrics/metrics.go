@@ -0,0 +1,315 @@
+// Package metrics implements counters and histograms exported in Prometheus text format.
+//
+// Backend needs only labeled counters, histograms and gauge functions, so they are
+// written here instead of pulling client_golang with its dependencies into the build.
+// Output follows text exposition format 0.0.4 and can be scraped by Prometheus as is.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are histogram buckets in seconds suitable for request latency
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is registry used by backend packages
+var Default = NewRegistry()
+
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics, they are written sorted by name
+type Registry struct {
+	lock       sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic("metric " + c.name() + " is already registered")
+		}
+	}
+	r.collectors = append(r.collectors, c)
+	sort.Slice(r.collectors, func(i, j int) bool {
+		return r.collectors[i].name() < r.collectors[j].name()
+	})
+}
+
+// WriteTo writes all metrics in Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.lock.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.lock.Unlock()
+
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	err := buf.Flush()
+	return counter.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats {name="value",...}, extra pair is appended when it's not empty
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	var parts []string
+
+	for i, name := range names {
+		parts = append(parts, name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+extraValue+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// seriesKey joins label values, it's used as map key
+func seriesKey(labels []string, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type metric struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (m *metric) name() string {
+	return m.metricName
+}
+
+// Counter is monotonically increasing value with optional labels
+type Counter struct {
+	metric
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{metric: metric{name, help, labels}, values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+// Inc increments counter of series with label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) Add(delta float64, values ...string) {
+	key := seriesKey(c.labels, values)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[key] += delta
+}
+
+// Value returns current value of series
+func (c *Counter) Value(values ...string) float64 {
+	key := seriesKey(c.labels, values)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		labels := formatLabels(c.labels, strings.Split(key, "\xff"), "", "")
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labels, formatFloat(c.values[key]))
+	}
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	metric
+	buckets []float64
+	lock    sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		metric:  metric{name, help, labels},
+		buckets: append([]float64{}, buckets...),
+		series:  make(map[string]*histogramSeries),
+	}
+	sort.Float64s(h.buckets)
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, values ...string) {
+	key := seriesKey(h.labels, values)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	// counts aren't cumulative, they are summed on write
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += value
+}
+
+// ObserveDuration observes duration since start in seconds
+func (h *Histogram) ObserveDuration(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// Count returns number of observations of series
+func (h *Histogram) Count(values ...string) uint64 {
+	key := seriesKey(h.labels, values)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if series, ok := h.series[key]; ok {
+		return series.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	writeHeader(w, h.metricName, h.help, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		values := strings.Split(key, "\xff")
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			labels := formatLabels(h.labels, values, "le", formatFloat(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labels, cumulative)
+		}
+		labels := formatLabels(h.labels, values, "le", "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labels, series.count)
+		labels = formatLabels(h.labels, values, "", "")
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labels, formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labels, series.count)
+	}
+}
+
+// gaugeFunc reads value on every scrape
+type gaugeFunc struct {
+	metric
+	fn func() float64
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{metric: metric{metricName: name, help: help}, fn: fn})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.fn()))
+}
+
+// runtimeCollector writes go runtime stats, memory stats are read once per scrape
+type runtimeCollector struct {
+	start time.Time
+}
+
+// RegisterRuntime adds go runtime and process metrics
+func (r *Registry) RegisterRuntime() {
+	r.register(&runtimeCollector{start: time.Now()})
+}
+
+func (c *runtimeCollector) name() string {
+	return "go_"
+}
+
+func (c *runtimeCollector) write(w *bufio.Writer) {
+	var stats runtime.MemStats
+
+	runtime.ReadMemStats(&stats)
+	gauges := []struct {
+		name, help, kind string
+		value            float64
+	}{
+		{"go_goroutines", "Number of goroutines.", "gauge", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "Bytes of allocated heap objects.", "gauge", float64(stats.HeapAlloc)},
+		{"go_memstats_heap_inuse_bytes", "Bytes in in-use heap spans.", "gauge", float64(stats.HeapInuse)},
+		{"go_memstats_sys_bytes", "Bytes obtained from system.", "gauge", float64(stats.Sys)},
+		{"go_memstats_mallocs_total", "Total number of allocated heap objects.", "counter", float64(stats.Mallocs)},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", "counter", float64(stats.NumGC)},
+		{"go_gc_pause_seconds_total", "Total GC pause time.", "counter", float64(stats.PauseTotalNs) / float64(time.Second)},
+		{"process_start_time_seconds", "Start time of the process since unix epoch in seconds.", "gauge", float64(c.start.Unix())},
+	}
+	writeHeader(w, "go_info", "Information about the Go environment.", "gauge")
+	fmt.Fprintf(w, "go_info{version=%q} 1\n", runtime.Version())
+	for _, g := range gauges {
+		writeHeader(w, g.name, g.help, g.kind)
+		fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.value))
+	}
+}
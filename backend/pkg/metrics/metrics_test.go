@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	var out strings.Builder
+
+	registry := NewRegistry()
+	counter := registry.NewCounter("test_requests_total", "Requests.", "route")
+	histogram := registry.NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	registry.NewGaugeFunc("test_value", "Value.", func() float64 { return 1.5 })
+	counter.Inc("/a")
+	counter.Add(2, `/b"\`)
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(5, "/a")
+	if _, err := registry.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/a",le="0.1"} 1
+test_latency_seconds_bucket{route="/a",le="1"} 2
+test_latency_seconds_bucket{route="/a",le="+Inf"} 3
+test_latency_seconds_sum{route="/a"} 5.55
+test_latency_seconds_count{route="/a"} 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a"} 1
+test_requests_total{route="/b\"\\"} 2
+# HELP test_value Value.
+# TYPE test_value gauge
+test_value 1.5
+`
+	if out.String() != expected {
+		t.Errorf("Incorrect output:\n%s", out.String())
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	var out strings.Builder
+
+	registry := NewRegistry()
+	registry.RegisterRuntime()
+	registry.WriteTo(&out)
+	for _, name := range []string{"go_goroutines ", "go_memstats_alloc_bytes ", "go_gc_cycles_total ", "go_info{version="} {
+		if !strings.Contains(out.String(), "\n"+name) {
+			t.Errorf("Metric %s is missing", name)
+		}
+	}
+}
+
+func TestLabelsMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic")
+		}
+	}()
+	NewRegistry().NewCounter("test_total", "Test.", "a", "b").Inc("a")
+}
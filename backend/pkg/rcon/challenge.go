@@ -0,0 +1,101 @@
+package rcon
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"time"
+)
+
+// invalidChallengeResponse is whole response to command signed with unknown challenge,
+// darkplaces usually ignores such command, so it's retried only when this exact reply is received
+const invalidChallengeResponse = "Invalid challenge.\n"
+
+var errNoChallenge = errors.New("server didn't send challenge")
+
+// challengeConn is socket of challenge mode. Server issues challenge for source address
+// and forgets it after successful command to prevent replay, so challenge can't be cached
+// between commands. Every command uses new socket with new challenge and packets of
+// previous commands can't be mistaken for its response
+type challengeConn struct {
+	conn   *net.UDPConn
+	remote *net.UDPAddr
+}
+
+// dialChallenge opens socket for ip:port, packets from other addresses are dropped by Read
+func dialChallenge(addr string) (*challengeConn, error) {
+	remote, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	network := "udp6"
+	if remote.IP.To4() != nil {
+		network = "udp4"
+	}
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &challengeConn{conn: conn, remote: remote}, nil
+}
+
+// Read returns packets of server, packets from other addresses are dropped
+func (c *challengeConn) Read(p []byte) (int, error) {
+	for {
+		n, addr, err := c.conn.ReadFromUDP(p)
+		if err != nil {
+			return 0, err
+		}
+		if addr.IP.Equal(c.remote.IP) && addr.Port == c.remote.Port {
+			return n, nil
+		}
+	}
+}
+
+func (c *challengeConn) Write(p []byte) (int, error) {
+	return c.conn.WriteToUDP(p, c.remote)
+}
+
+func (c *challengeConn) Close() error {
+	return c.conn.Close()
+}
+
+// getChallenge requests challenge for socket
+func (c *challengeConn) getChallenge(server *ServerConfig, buf []byte) ([]byte, error) {
+	start := time.Now()
+	if _, err := c.Write([]byte(ChallengeRequest)); err != nil {
+		return nil, err
+	}
+	for {
+		// read until we receive challenge response
+		n, err := c.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasPrefix(buf[:n], []byte(ChallengeHeader)) {
+			continue
+		}
+		challenge := buf[len(ChallengeHeader):n]
+		if i := bytes.IndexByte(challenge, '\x00'); i >= 0 {
+			challenge = challenge[:i]
+		}
+		if len(challenge) == 0 {
+			return nil, errNoChallenge
+		}
+		observeChallenge(server, challengeFetched, time.Since(start))
+		return append([]byte{}, challenge...), nil
+	}
+}
+
+// send sends command signed with new challenge
+func (c *challengeConn) send(server *ServerConfig, password Secret, cmd string, buf []byte) error {
+	var w bytes.Buffer
+
+	challenge, err := c.getChallenge(server, buf)
+	if err != nil {
+		return err
+	}
+	RconSecureChallengePacket(cmd, string(password), challenge, &w)
+	_, err = c.Write(w.Bytes())
+	return err
+}
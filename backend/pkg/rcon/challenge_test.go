@@ -0,0 +1,206 @@
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const srconPrefix = QHeader + "srcon HMAC-MD4 CHALLENGE "
+
+// fakeChallengeServer answers challenge mode commands with echo of command,
+// challenges are issued for client address and forgotten after successful command
+// like darkplaces does, commands with wrong password or challenge are ignored
+type fakeChallengeServer struct {
+	conn    *net.UDPConn
+	spoofer *net.UDPConn
+
+	lock       sync.Mutex
+	passwords  []string
+	challenges map[string]string
+	requests   int
+	// accepted is password of last accepted command
+	accepted string
+	// spoof sends fake challenge from other address before real one
+	spoof bool
+	// rejectNext forgets challenge before next command and replies with invalidChallengeResponse
+	rejectNext bool
+}
+
+func newFakeChallengeServer(t *testing.T) *fakeChallengeServer {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spoofer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeChallengeServer{conn: conn, spoofer: spoofer, passwords: []string{"secret"}, challenges: make(map[string]string)}
+	t.Cleanup(func() {
+		conn.Close()
+		spoofer.Close()
+	})
+	go s.serve()
+	return s
+}
+
+func (s *fakeChallengeServer) config() *ServerConfig {
+	return &ServerConfig{
+		Server:       "127.0.0.1",
+		Port:         s.conn.LocalAddr().(*net.UDPAddr).Port,
+		RconPassword: Secret(s.passwords[0]),
+		RconMode:     rconChallengeSecureMode,
+	}
+}
+
+// rejectChallenge makes server reject challenge of next command with explicit reply
+func (s *fakeChallengeServer) rejectChallenge() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rejectNext = true
+}
+
+// setPasswords replaces passwords accepted by server
+func (s *fakeChallengeServer) setPasswords(passwords ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.passwords = passwords
+}
+
+func (s *fakeChallengeServer) acceptedPassword() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.accepted
+}
+
+func (s *fakeChallengeServer) challengeRequests() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+func (s *fakeChallengeServer) serve() {
+	buf := make([]byte, XonMSS)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		packet := buf[:n]
+		s.lock.Lock()
+		switch {
+		case bytes.Equal(packet, []byte(ChallengeRequest)):
+			s.requests++
+			challenge := fmt.Sprintf("c%d", s.requests)
+			s.challenges[addr.String()] = challenge
+			if s.spoof {
+				s.spoofer.WriteToUDP([]byte(ChallengeHeader+"spoofed\x00"), addr)
+			}
+			s.conn.WriteToUDP([]byte(ChallengeHeader+challenge+"\x00"), addr)
+		case bytes.HasPrefix(packet, []byte(srconPrefix)):
+			// prefix is followed by 16 bytes of hmac, challenge and command
+			rest := string(packet[len(srconPrefix)+17:])
+			challenge, cmd, _ := strings.Cut(rest, " ")
+			if s.rejectNext {
+				s.rejectNext = false
+				delete(s.challenges, addr.String())
+				s.conn.WriteToUDP([]byte(RconResponseHeader+invalidChallengeResponse), addr)
+				break
+			}
+			if challenge != s.challenges[addr.String()] {
+				break
+			}
+			for _, password := range s.passwords {
+				var expected bytes.Buffer
+				RconSecureChallengePacket(cmd, password, []byte(challenge), &expected)
+				if bytes.Equal(packet, expected.Bytes()) {
+					s.accepted = password
+					delete(s.challenges, addr.String())
+					s.conn.WriteToUDP([]byte(RconResponseHeader+"echo "+cmd+"\n"), addr)
+					break
+				}
+			}
+		}
+		s.lock.Unlock()
+	}
+}
+
+func readLine(r io.Reader) (string, error) {
+	return bufio.NewReader(r).ReadString('\n')
+}
+
+func queryEcho(t *testing.T, server *ServerConfig, cmd string) {
+	line, err := query(server, time.Now().Add(time.Second), cmd, readLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "echo "+cmd+"\n" {
+		t.Errorf("Incorrect response %q", line)
+	}
+}
+
+func TestChallengePerCommand(t *testing.T) {
+	fake := newFakeChallengeServer(t)
+	server := fake.config()
+	for i := 0; i < 3; i++ {
+		queryEcho(t, server, fmt.Sprintf("status %d", i))
+	}
+	if fake.challengeRequests() != 3 {
+		t.Error("Every command should use new challenge ", fake.challengeRequests())
+	}
+}
+
+func TestChallengeInvalid(t *testing.T) {
+	fake := newFakeChallengeServer(t)
+	server := fake.config()
+	fake.rejectChallenge()
+	queryEcho(t, server, "status")
+	if fake.challengeRequests() != 2 {
+		t.Error("Rejected command should be sent with new challenge ", fake.challengeRequests())
+	}
+
+	// response mentioning invalid challenge isn't rejection
+	queryEcho(t, server, "say Invalid challenge.")
+	if fake.challengeRequests() != 3 {
+		t.Error("Only exact rejection should be retried ", fake.challengeRequests())
+	}
+}
+
+func TestChallengeSpoofed(t *testing.T) {
+	fake := newFakeChallengeServer(t)
+	fake.lock.Lock()
+	fake.spoof = true
+	fake.lock.Unlock()
+	queryEcho(t, fake.config(), "status")
+}
+
+func TestChallengeParallel(t *testing.T) {
+	var wg sync.WaitGroup
+
+	fake := newFakeChallengeServer(t)
+	server := fake.config()
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				cmd := fmt.Sprintf("cmd %d %d", i, j)
+				line, err := query(server, time.Now().Add(time.Second), cmd, readLine)
+				if err != nil || line != "echo "+cmd+"\n" {
+					t.Errorf("Incorrect response %q %v", line, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if fake.challengeRequests() != 40 {
+		t.Error("Every command should use new challenge ", fake.challengeRequests())
+	}
+}
@@ -0,0 +1,109 @@
+package rcon
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	CredentialPassword   = "rcon_password"
+	CredentialRestricted = "rcon_restricted_password"
+)
+
+// Credential is password used for rcon command, Name tells which password from config it is
+type Credential struct {
+	Name     string
+	Password Secret
+}
+
+// rotationCredential returns name of password from rcon_passwords
+func rotationCredential(i int) string {
+	return "rcon_passwords[" + strconv.Itoa(i) + "]"
+}
+
+// RotationActive reports whether rcon_passwords are still tried at time now
+func (s *ServerConfig) RotationActive(now time.Time) bool {
+	return s.RconRotationUntil != nil && now.Before(*s.RconRotationUntil)
+}
+
+// Credentials returns passwords in order they are tried. Restricted password is tried first
+// for read only commands and full password is fallback when it's rejected,
+// rcon_passwords are tried only until end of rotation window
+func (s *ServerConfig) Credentials(readOnly bool) []Credential {
+	var credentials []Credential
+
+	if readOnly && s.RconRestrictedPassword != "" {
+		credentials = append(credentials, Credential{Name: CredentialRestricted, Password: s.RconRestrictedPassword})
+	}
+	credentials = append(credentials, Credential{Name: CredentialPassword, Password: s.RconPassword})
+	if s.RotationActive(time.Now()) {
+		for i, password := range s.RconPasswords {
+			credentials = append(credentials, Credential{Name: rotationCredential(i), Password: password})
+		}
+	}
+	return credentials
+}
+
+// AllCredentials returns every configured password regardless of rotation window, it's used for validation
+func (s *ServerConfig) AllCredentials() []Credential {
+	credentials := []Credential{{Name: CredentialPassword, Password: s.RconPassword}}
+	for i, password := range s.RconPasswords {
+		credentials = append(credentials, Credential{Name: rotationCredential(i), Password: password})
+	}
+	if s.RconRestrictedPassword != "" {
+		credentials = append(credentials, Credential{Name: CredentialRestricted, Password: s.RconRestrictedPassword})
+	}
+	return credentials
+}
+
+// lastCredentials keeps name of credential which succeeded last time for server,
+// read only and admin commands are kept separately, so they don't replace each other
+var lastCredentials sync.Map
+
+func credentialsKey(server *ServerConfig, readOnly bool) string {
+	if readOnly && server.RconRestrictedPassword != "" {
+		return serverLabel(server) + "/read_only"
+	}
+	return serverLabel(server)
+}
+
+// orderCredentials returns credentials of server with one which succeeded last time first,
+// known is set when first credential succeeded before
+func orderCredentials(server *ServerConfig, readOnly bool) (credentials []Credential, known bool) {
+	credentials = server.Credentials(readOnly)
+	last, ok := lastCredentials.Load(credentialsKey(server, readOnly))
+	if !ok {
+		return credentials, false
+	}
+	for i, credential := range credentials {
+		if credential.Name == last {
+			ordered := append([]Credential{credential}, credentials[:i]...)
+			return append(ordered, credentials[i+1:]...), true
+		}
+	}
+	return credentials, false
+}
+
+// credentialSucceeded remembers credential of server, change of credential is logged
+func credentialSucceeded(server *ServerConfig, readOnly bool, credential Credential) {
+	observeCredential(server, credential.Name)
+	previous, loaded := lastCredentials.Swap(credentialsKey(server, readOnly), credential.Name)
+	if loaded && previous != credential.Name {
+		getLogger().Warn("rcon credential changed", "server", server.Name, "addr", server.Addr(),
+			"credential", credential.Name, "previous", previous)
+	}
+}
+
+// credentialFailed forgets credential which succeeded before, so all credentials are tried next time
+func credentialFailed(server *ServerConfig, readOnly bool, credential Credential) {
+	if lastCredentials.CompareAndDelete(credentialsKey(server, readOnly), credential.Name) {
+		getLogger().Warn("rcon credential stopped working", "server", server.Name, "addr", server.Addr(),
+			"credential", credential.Name)
+	}
+}
+
+// credentialDeadline splits time left till deadline equally between credentials left
+func credentialDeadline(deadline time.Time, left int) time.Time {
+	return time.Now().Add(time.Until(deadline) / time.Duration(left))
+}
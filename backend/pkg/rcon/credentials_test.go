@@ -0,0 +1,118 @@
+package rcon
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func credentialNames(credentials []Credential) []string {
+	var result []string
+	for _, credential := range credentials {
+		result = append(result, credential.Name)
+	}
+	return result
+}
+
+func TestCredentialsOrder(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	server := &ServerConfig{
+		Server:                 "127.0.0.1",
+		Port:                   26100,
+		RconPassword:           "new",
+		RconPasswords:          []Secret{"old", "older"},
+		RconRotationUntil:      &until,
+		RconRestrictedPassword: "viewer",
+	}
+	expected := []string{CredentialPassword, "rcon_passwords[0]", "rcon_passwords[1]"}
+	if result := credentialNames(server.Credentials(false)); !slices.Equal(result, expected) {
+		t.Error("Incorrect credentials ", result)
+	}
+	expected = []string{CredentialRestricted, CredentialPassword, "rcon_passwords[0]", "rcon_passwords[1]"}
+	if result := credentialNames(server.Credentials(true)); !slices.Equal(result, expected) {
+		t.Error("Incorrect read only credentials ", result)
+	}
+	credentialSucceeded(server, false, server.Credentials(false)[1])
+	credentials, known := orderCredentials(server, false)
+	expected = []string{"rcon_passwords[0]", CredentialPassword, "rcon_passwords[1]"}
+	if result := credentialNames(credentials); !known || !slices.Equal(result, expected) {
+		t.Error("Last succeeded credential should be first ", result)
+	}
+	// read only queries keep own credential
+	if credentials, known := orderCredentials(server, true); known || credentials[0].Name != CredentialRestricted {
+		t.Error("Read only credentials shouldn't be reordered ", credentialNames(credentials))
+	}
+
+	// rotation passwords aren't used after rotation window
+	until = time.Now().Add(-time.Minute)
+	if result := credentialNames(server.Credentials(false)); !slices.Equal(result, []string{CredentialPassword}) {
+		t.Error("Rotation passwords should expire ", result)
+	}
+	if _, known := orderCredentials(server, false); known {
+		t.Error("Expired credential shouldn't be known")
+	}
+}
+
+func TestCredentialsRotation(t *testing.T) {
+	fake := newFakeChallengeServer(t)
+	server := fake.config()
+	until := time.Now().Add(time.Hour)
+	server.RconPassword = "rotated"
+	server.RconPasswords = []Secret{"secret"}
+	server.RconRotationUntil = &until
+
+	start := time.Now()
+	queryEcho(t, server, "status")
+	if fake.acceptedPassword() != "secret" {
+		t.Error("Old password should be used ", fake.acceptedPassword())
+	}
+	if time.Since(start) < time.Millisecond*400 {
+		t.Error("New password should be tried first ", time.Since(start))
+	}
+	// succeeded password is tried first next time
+	start = time.Now()
+	queryEcho(t, server, "status")
+	if time.Since(start) > time.Millisecond*200 {
+		t.Error("Old password should be reused ", time.Since(start))
+	}
+
+	// succeeded password gets whole deadline, it's forgotten when it fails
+	fake.setPasswords("rotated")
+	if _, err := query(server, time.Now().Add(time.Millisecond*200), "status", readLine); !isTimeout(err) {
+		t.Error("Old password should time out ", err)
+	}
+	queryEcho(t, server, "status")
+	if fake.acceptedPassword() != "rotated" {
+		t.Error("New password should be used after rotation ", fake.acceptedPassword())
+	}
+}
+
+func TestCredentialsRestricted(t *testing.T) {
+	fake := newFakeChallengeServer(t)
+	fake.setPasswords("secret", "viewer")
+	server := fake.config()
+	server.RconRestrictedPassword = "viewer"
+
+	if _, err := readOnlyQuery(server, time.Now().Add(time.Second), "status", readLine); err != nil {
+		t.Fatal(err)
+	}
+	if fake.acceptedPassword() != "viewer" {
+		t.Error("Restricted password should be used for read only query ", fake.acceptedPassword())
+	}
+	queryEcho(t, server, "sv_cmd bans")
+	if fake.acceptedPassword() != "secret" {
+		t.Error("Full password should be used for admin query ", fake.acceptedPassword())
+	}
+
+	// full password is fallback when restricted one is rejected
+	fake.setPasswords("secret")
+	if _, err := readOnlyQuery(server, time.Now().Add(time.Millisecond*200), "status", readLine); !isTimeout(err) {
+		t.Error("Known restricted password should time out ", err)
+	}
+	if _, err := readOnlyQuery(server, time.Now().Add(time.Second), "status", readLine); err != nil {
+		t.Fatal(err)
+	}
+	if fake.acceptedPassword() != "secret" {
+		t.Error("Full password should be used when restricted one fails ", fake.acceptedPassword())
+	}
+}
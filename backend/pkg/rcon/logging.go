@@ -0,0 +1,74 @@
+package rcon
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger sets logger for rcon queries, slog.Default is used when it's not set
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+func getLogger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// QueryError describes failed rcon command
+type QueryError struct {
+	// Server is name of server from config, it can be empty
+	Server  string
+	Addr    string
+	Command string
+	// Label is command label of metrics
+	Label string
+	Err   error
+}
+
+func newQueryError(server *ServerConfig, cmd string, err error) *QueryError {
+	return &QueryError{
+		Server:  server.Name,
+		Addr:    server.Addr(),
+		Command: commandName(cmd),
+		Label:   commandLabel(cmd),
+		Err:     err,
+	}
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("rcon %s to %s: %v", e.Command, e.Addr, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// LogAttrs returns server and command fields for logging
+func (e *QueryError) LogAttrs() []any {
+	return []any{"server", e.Server, "addr", e.Addr, "command", e.Command}
+}
+
+// commandName hides arguments of command, they can contain chat messages and ban reasons
+func commandName(cmd string) string {
+	var names []string
+
+	for _, part := range strings.Split(cmd, "\x00") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if name == "sv_cmd" && len(fields) > 1 {
+			name += " " + fields[1]
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "; ")
+}
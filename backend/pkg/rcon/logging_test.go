@@ -0,0 +1,72 @@
+package rcon
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandName(t *testing.T) {
+	tests := map[string]string{
+		"sv_public\x00status 1":       "sv_public; status",
+		"sv_cmd ban 1.2.3.4 60 \"x\"": "sv_cmd ban",
+		"say \"secret message\"":      "say",
+		"":                            "",
+	}
+	for cmd, expected := range tests {
+		if name := commandName(cmd); name != expected {
+			t.Errorf("Incorrect name for %q: %q", cmd, name)
+		}
+	}
+}
+
+func TestCommandLabel(t *testing.T) {
+	tests := map[string]string{
+		"sv_public\x00status 1":             "status",
+		"prvm_globalget server worldstatus": "info",
+		"sv_cmd printstats":                 "scores",
+		"sv_cmd ban 1.2.3.4 60 \"x\"":       "bans",
+		"g_maplist\x00g_maplist_shuffle":    "cvars",
+		"visitor_cvar_1\x00visitor_cvar_2":  "cvars",
+		"g_maplist \"a b c\"":               "cvars",
+		"say \"secret message\"":            "say",
+		"sv_cmd":                            "other",
+		"":                                  "other",
+	}
+	for cmd, expected := range tests {
+		if label := commandLabel(cmd); label != expected {
+			t.Errorf("Incorrect label for %q: %q", cmd, label)
+		}
+	}
+}
+
+func TestQueryWithRetriesLogging(t *testing.T) {
+	var buf bytes.Buffer
+
+	SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+	server := &ServerConfig{Server: "127.0.0.1", Port: 26000, Name: "ctf"}
+	_, err := QueryWithRetries(time.Millisecond, 2, func(deadline time.Time) (int, error) {
+		return 0, newQueryError(server, "sv_cmd printstats", errors.New("timeout"))
+	})
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Server != "ctf" {
+		t.Fatal("Expected query error ", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %q", lines)
+	}
+	expected := []string{
+		`level=DEBUG msg="rcon query failed" server=ctf addr=127.0.0.1:26000 command="sv_cmd printstats" attempt=1 retries=2`,
+		`level=WARN msg="rcon query failed" server=ctf addr=127.0.0.1:26000 command="sv_cmd printstats" attempt=2 retries=2`,
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, expected[i]) {
+			t.Errorf("Incorrect log line %q", line)
+		}
+	}
+}
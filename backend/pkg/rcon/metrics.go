@@ -0,0 +1,134 @@
+package rcon
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TheRegulars/website/backend/pkg/metrics"
+)
+
+const (
+	resultOK         = "ok"
+	resultTimeout    = "timeout"
+	resultParseError = "parse_error"
+	resultError      = "error"
+	// last known address was used after failed lookup
+	resultStale = "stale"
+
+	challengeFetched = "fetched"
+	// server rejected challenge
+	challengeInvalid = "invalid"
+)
+
+var queriesTotal = metrics.Default.NewCounter("backend_rcon_queries_total",
+	"Rcon queries by server, command and result.", "server", "command", "result")
+var queryDuration = metrics.Default.NewHistogram("backend_rcon_query_duration_seconds",
+	"Duration of successful rcon queries.", metrics.DefaultBuckets, "server", "command")
+var retriesTotal = metrics.Default.NewCounter("backend_rcon_retries_total",
+	"Retried rcon queries by server and command.", "server", "command")
+var challengesTotal = metrics.Default.NewCounter("backend_rcon_challenges_total",
+	"Challenges of challenge mode by server and result.", "server", "result")
+var challengeRTT = metrics.Default.NewHistogram("backend_rcon_challenge_rtt_seconds",
+	"Round trip time of challenge requests.", metrics.DefaultBuckets, "server")
+var credentialsTotal = metrics.Default.NewCounter("backend_rcon_credentials_total",
+	"Successful rcon queries by server and credential.", "server", "credential")
+var dnsLookupsTotal = metrics.Default.NewCounter("backend_rcon_dns_lookups_total",
+	"DNS lookups of server hostnames by result.", "result")
+
+func serverLabel(server *ServerConfig) string {
+	if server.Name != "" {
+		return server.Name
+	}
+	return server.Addr()
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// commandLabels are metric labels of commands by first word, sv_cmd commands use second word
+var commandLabels = map[string]string{
+	"sv_public":      "status",
+	"status":         "status",
+	"prvm_globalget": "info",
+	"memstats":       "memstats",
+	"say":            "say",
+	"printstats":     "scores",
+	"bans":           "bans",
+	"ban":            "bans",
+	"unban":          "bans",
+}
+
+// commandLabel returns label of command for metrics from fixed set, it never includes
+// arguments or cvar names, they can come from request and create unbounded series
+func commandLabel(cmd string) string {
+	first, _, _ := strings.Cut(cmd, "\x00")
+	fields := strings.Fields(first)
+	if len(fields) == 0 {
+		return "other"
+	}
+	name := fields[0]
+	if name == "sv_cmd" && len(fields) > 1 {
+		name = fields[1]
+	} else if name == "sv_cmd" {
+		return "other"
+	}
+	if label, ok := commandLabels[name]; ok {
+		return label
+	}
+	// other commands are cvar names, cvars are read by name and set by name with value
+	if ValidCvarName(name) {
+		return "cvars"
+	}
+	return "other"
+}
+
+// observeQuery counts query, parsed tells that error was returned by parser
+func observeQuery(server *ServerConfig, cmd string, start time.Time, err error, parsed bool) {
+	result := resultOK
+	switch {
+	case err == nil:
+		queryDuration.ObserveDuration(start, serverLabel(server), commandLabel(cmd))
+	case isTimeout(err):
+		result = resultTimeout
+	case parsed:
+		result = resultParseError
+	default:
+		result = resultError
+	}
+	queriesTotal.Inc(serverLabel(server), commandLabel(cmd), result)
+}
+
+func observeRetry(err error) {
+	var queryErr *QueryError
+
+	if errors.As(err, &queryErr) {
+		server := queryErr.Server
+		if server == "" {
+			server = queryErr.Addr
+		}
+		retriesTotal.Inc(server, queryErr.Label)
+	} else {
+		retriesTotal.Inc("", "")
+	}
+}
+
+func observeLookup(result string) {
+	dnsLookupsTotal.Inc(result)
+}
+
+// observeChallenge counts challenge use, rtt is observed for fetched challenges
+func observeChallenge(server *ServerConfig, result string, rtt time.Duration) {
+	challengesTotal.Inc(serverLabel(server), result)
+	if result == challengeFetched {
+		challengeRTT.Observe(rtt.Seconds(), serverLabel(server))
+	}
+}
+
+func observeCredential(server *ServerConfig, credential string) {
+	credentialsTotal.Inc(serverLabel(server), credential)
+}
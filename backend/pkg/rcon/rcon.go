@@ -3,10 +3,15 @@ package rcon
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -18,22 +23,112 @@ const (
 	XonMSS                  = 1460
 )
 
+const redactedSecret = "<redacted>"
+
+// Secret holds sensitive value like rcon password, it's never printed or
+// marshaled to json as is
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redactedSecret
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
 type ServerConfig struct {
-	Server       string `json:"server" yaml:"server"`
-	Port         int    `json:"port" yaml:"port"`
-	RconPassword string `json:"rcon_password" yaml:"rcon_password"`
-	RconMode     int    `json:"rcon_mode" yaml:"rcon_mode"`
+	Server           string `json:"server" yaml:"server"`
+	Port             int    `json:"port" yaml:"port"`
+	RconPassword     Secret `json:"rcon_password,omitempty" yaml:"rcon_password"`
+	RconPasswordFile string `json:"rcon_password_file,omitempty" yaml:"rcon_password_file"`
+	RconPasswordEnv  string `json:"rcon_password_env,omitempty" yaml:"rcon_password_env"`
+	RconMode         int    `json:"rcon_mode" yaml:"rcon_mode"`
+	// RconPasswords are tried after RconPassword in order, they are used during password rotation
+	RconPasswords []Secret `json:"rcon_passwords,omitempty" yaml:"rcon_passwords,omitempty"`
+	// RconRotationUntil is end of rotation window, RconPasswords aren't tried after it
+	RconRotationUntil *time.Time `json:"rcon_rotation_until,omitempty" yaml:"rcon_rotation_until,omitempty"`
+	// RconRestrictedPassword is used for read only queries like status, server should
+	// allow them in rcon_restricted_commands
+	RconRestrictedPassword Secret `json:"rcon_restricted_password,omitempty" yaml:"rcon_restricted_password,omitempty"`
+	// IPFamily is preferred address family of hostname, 4 or 6, order of resolver is used when it's 0
+	IPFamily int `json:"ip_family,omitempty" yaml:"ip_family,omitempty"`
+	// Display is shown on site, it isn't used for queries
+	Display *ServerDisplay `json:"display,omitempty" yaml:"display,omitempty"`
+	// Labels are added to exported metrics and server list
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Name is key of server in config, it's used in logs
+	Name string `json:"-" yaml:"-"`
+}
+
+const (
+	VisibilityPublic = "public"
+	// hidden servers aren't listed, but they are available by name
+	VisibilityHidden = "hidden"
+	// internal servers are available only for admin routes and exporter
+	VisibilityInternal = "internal"
+)
+
+type ServerDisplay struct {
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Region      string `json:"region,omitempty" yaml:"region,omitempty"`
+	Category    string `json:"category,omitempty" yaml:"category,omitempty"`
+	// JoinURL is xonotic://server:port by default
+	JoinURL    string `json:"join_url,omitempty" yaml:"join_url,omitempty"`
+	Order      int    `json:"order,omitempty" yaml:"order,omitempty"`
+	Visibility string `json:"visibility,omitempty" yaml:"visibility,omitempty"`
+}
+
+func (s *ServerConfig) Addr() string {
+	return net.JoinHostPort(s.Server, strconv.Itoa(s.Port))
+}
+
+// Visibility returns visibility from display section, servers are public by default
+func (s *ServerConfig) Visibility() string {
+	if s.Display == nil || s.Display.Visibility == "" {
+		return VisibilityPublic
+	}
+	return s.Display.Visibility
+}
+
+// JoinURL returns configured join url or xonotic:// url of server address
+func (s *ServerConfig) JoinURL() string {
+	if s.Display != nil && s.Display.JoinURL != "" {
+		return s.Display.JoinURL
+	}
+	return "xonotic://" + s.Addr()
 }
 
+const (
+	PlayerTypeBot       = "bot"
+	PlayerTypeSpectator = "spectator"
+	PlayerTypePlayer    = "player"
+	AddressBot          = "botclient"
+	AddressLocal        = "local"
+	SpectatorFrags      = -666
+)
+
 type Player struct {
-	IP     string `json:"-"`
-	PL     int64  `json:"pl"`
-	Ping   int64  `json:"ping"`
-	Time   string `json:"time"`
-	Frags  int64  `json:"frags"`
-	Number int32  `json:"no"`
-	Name   string `json:"name"`
-	IsBot  bool   `json:"is_bot"`
+	IP      string `json:"-"`
+	Port    int    `json:"-"`
+	IsLocal bool   `json:"-"`
+	PL      int64  `json:"pl"`
+	Ping    int64  `json:"ping"`
+	Time    int64  `json:"time"`
+	Frags   int64  `json:"frags"`
+	Number  int32  `json:"no"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Country string `json:"country,omitempty"`
+	IsBot   bool   `json:"is_bot"`
 }
 
 type ServerStatus struct {
@@ -52,17 +147,52 @@ type ServerStatus struct {
 	PlayersActive int64    `json:"players_count"`
 	PlayersMax    int64    `json:"players_max"`
 	Players       []Player `json:"players,omitempty"`
+	// Address is resolved ip:port of server, it isn't part of rcon response
+	Address string `json:"address,omitempty"`
+}
+
+// server flags from qcsrc/common/constants.qh
+const (
+	ServerFlagAllowFullbright   = 1
+	ServerFlagTeamplay          = 2
+	ServerFlagPlayerStats       = 4
+	ServerFlagPlayerStatsCustom = 8
+)
+
+type ServerFlags struct {
+	AllowFullbright   bool `json:"allow_fullbright"`
+	Teamplay          bool `json:"teamplay"`
+	PlayerStats       bool `json:"player_stats"`
+	PlayerStatsCustom bool `json:"player_stats_custom"`
+}
+
+// ScoreLabel is score column name with sort flags, encoded by
+// "!!" (primary), "!" (secondary) and "<" (lower is better) suffixes
+type ScoreLabel struct {
+	Name          string `json:"name"`
+	Primary       bool   `json:"primary"`
+	Secondary     bool   `json:"secondary"`
+	LowerIsBetter bool   `json:"lower_is_better"`
+}
+
+type TeamScore struct {
+	Team   int     `json:"team"`
+	Scores []int64 `json:"scores"`
 }
 
 type ServerInfo struct {
-	Gametype          string `json:"gametype"`
-	Version           string `json:"version"`
-	PureChangesCount  int64  `json:"pure_changes_count"`
-	JoinAllowedCount  int64  `json:"join_allowed_count"`
-	ServerFlags       int32  `json:"server_flags"`
-	TermsOfServiceURL string `json:"terms_of_service"`
-	ModName           string `json:"mod_name"`
-	ScoreString       string `json:"score_string"`
+	Gametype          string       `json:"gametype"`
+	Version           string       `json:"version"`
+	PureChangesCount  int64        `json:"pure_changes_count"`
+	JoinAllowedCount  int64        `json:"join_allowed_count"`
+	ServerFlags       int32        `json:"server_flags"`
+	Flags             ServerFlags  `json:"flags"`
+	TermsOfServiceURL string       `json:"terms_of_service"`
+	ModName           string       `json:"mod_name"`
+	ScoreString       string       `json:"score_string"`
+	PlayerLabels      []ScoreLabel `json:"player_labels"`
+	TeamLabels        []ScoreLabel `json:"team_labels"`
+	TeamScores        []TeamScore  `json:"team_scores"`
 }
 
 type PlayerScores struct {
@@ -101,25 +231,34 @@ type ServerMetrics struct {
 	Memory       *ServerMemstats
 	PingDuration time.Duration
 	PingSeconds  float64
+	// Addr is resolved ip:port of server
+	Addr string
 }
 
 type rconReader struct {
-	conn  net.Conn
+	conn  io.ReadCloser
 	buf   []byte
 	slice []byte
 }
 
+// fill reads next response packet
+func (r *rconReader) fill() error {
+	for {
+		n, err := r.conn.Read(r.buf)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(r.buf[:n], []byte(RconResponseHeader)) {
+			r.slice = r.buf[len(RconResponseHeader):n]
+			return nil
+		}
+	}
+}
+
 func (r *rconReader) Read(p []byte) (int, error) {
 	if len(r.slice) == 0 {
-		for {
-			n, err := r.conn.Read(r.buf)
-			if err != nil {
-				return 0, err
-			}
-			if bytes.HasPrefix(r.buf[:n], []byte(RconResponseHeader)) {
-				r.slice = r.buf[len(RconResponseHeader):n]
-				break
-			}
+		if err := r.fill(); err != nil {
+			return 0, err
 		}
 	}
 	num := copy(p, r.slice)
@@ -131,50 +270,36 @@ func (r *rconReader) Close() error {
 	return r.conn.Close()
 }
 
-func rconExecute(server *ServerConfig, deadline time.Time, cmd string) (io.ReadCloser, error) {
-	var challenge []byte
+func rconExecute(server *ServerConfig, password Secret, deadline time.Time, cmd string) (*rconReader, error) {
 	var w bytes.Buffer
 
-	addr := net.JoinHostPort(server.Server, strconv.Itoa(server.Port))
-	conn, err := net.Dial("udp", addr)
+	addr, err := resolveAddr(server, deadline)
 	if err != nil {
 		return nil, err
 	}
-	conn.SetDeadline(deadline)
 	readBuffer := make([]byte, XonMSS)
-	// send rcon command
 	if server.RconMode == rconChallengeSecureMode {
-		_, err := conn.Write([]byte(ChallengeRequest))
+		conn, err := dialChallenge(addr)
 		if err != nil {
-			conn.Close()
 			return nil, err
 		}
-
-		for {
-			// read until we receive challenge response
-			n, err := conn.Read(readBuffer)
-			if err != nil {
-				conn.Close()
-				return nil, err
-			}
-			if bytes.HasPrefix(readBuffer[:n], []byte(ChallengeHeader)) {
-				challengeEnd := len(ChallengeHeader)
-
-				for i := len(ChallengeHeader); i < n; i++ {
-					if readBuffer[i] == '\x00' {
-						challengeEnd = i
-						break
-					}
-				}
-				challenge = readBuffer[len(ChallengeHeader):challengeEnd]
-				break
-			}
+		conn.conn.SetDeadline(deadline)
+		if err := conn.send(server, password, cmd, readBuffer); err != nil {
+			conn.Close()
+			return nil, err
 		}
-		RconSecureChallengePacket(cmd, server.RconPassword, challenge, &w)
-	} else if server.RconMode == rconTimeSecureMode {
-		RconSecureTimePacket(cmd, server.RconPassword, time.Now(), &w)
+		return &rconReader{conn: conn, buf: readBuffer, slice: nil}, nil
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(deadline)
+	// send rcon command
+	if server.RconMode == rconTimeSecureMode {
+		RconSecureTimePacket(cmd, string(password), time.Now(), &w)
 	} else {
-		RconNonSecurePacket(cmd, server.RconPassword, &w)
+		RconNonSecurePacket(cmd, string(password), &w)
 	}
 	_, err = conn.Write(w.Bytes())
 	if err != nil {
@@ -184,38 +309,121 @@ func rconExecute(server *ServerConfig, deadline time.Time, cmd string) (io.ReadC
 	return &rconReader{conn: conn, buf: readBuffer, slice: nil}, nil
 }
 
-func QueryRconStatus(server *ServerConfig, deadline time.Time) (*ServerStatus, error) {
-	reader, err := rconExecute(server, deadline, "sv_public\x00status 1")
+// rconQuery sends command which has response, in challenge mode command is sent again
+// on new socket when server replies that challenge is invalid
+func rconQuery(server *ServerConfig, password Secret, deadline time.Time, cmd string) (*rconReader, error) {
+	reader, err := rconExecute(server, password, deadline, cmd)
 	if err != nil {
 		return nil, err
 	}
-	defer reader.Close()
-	return ParseStatus(reader)
+	if _, ok := reader.conn.(*challengeConn); !ok {
+		return reader, nil
+	}
+	err = reader.fill()
+	if err == nil && string(reader.slice) == invalidChallengeResponse {
+		observeChallenge(server, challengeInvalid, 0)
+		reader.Close()
+		if reader, err = rconExecute(server, password, deadline, cmd); err != nil {
+			return nil, err
+		}
+		err = reader.fill()
+	}
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return reader, nil
 }
 
-func QueryRconInfo(server *ServerConfig, deadline time.Time) (*ServerInfo, error) {
-	reader, err := rconExecute(server, deadline, "prvm_globalget server worldstatus")
+// query executes command with full password and parses response, errors are returned as *QueryError
+func query[T any](server *ServerConfig, deadline time.Time, cmd string, parse func(io.Reader) (T, error)) (T, error) {
+	return queryWith(server, false, deadline, cmd, parse)
+}
+
+// readOnlyQuery is like query, but restricted password is used when it's configured
+func readOnlyQuery[T any](server *ServerConfig, deadline time.Time, cmd string, parse func(io.Reader) (T, error)) (T, error) {
+	return queryWith(server, true, deadline, cmd, parse)
+}
+
+// queryWith tries credentials in order, server doesn't respond to wrong password,
+// so next credential is tried only after timeout. Credential which succeeded before
+// gets whole deadline and it's forgotten when it fails
+func queryWith[T any](server *ServerConfig, readOnly bool, deadline time.Time, cmd string, parse func(io.Reader) (T, error)) (T, error) {
+	var result T
+	var reader *rconReader
+	var credential Credential
+	var err error
+
+	start := time.Now()
+	credentials, known := orderCredentials(server, readOnly)
+	if known {
+		credentials = credentials[:1]
+	}
+	for i := range credentials {
+		credential = credentials[i]
+		reader, err = rconQuery(server, credential.Password, credentialDeadline(deadline, len(credentials)-i), cmd)
+		if err == nil || !isTimeout(err) {
+			break
+		}
+	}
+	if known && err != nil && isTimeout(err) {
+		credentialFailed(server, readOnly, credential)
+	}
 	if err != nil {
-		return nil, err
+		observeQuery(server, cmd, start, err, false)
+		return result, newQueryError(server, cmd, err)
 	}
 	defer reader.Close()
-	return ParseServerInfo(reader)
+	result, err = parse(reader)
+	observeQuery(server, cmd, start, err, true)
+	if err != nil {
+		return result, newQueryError(server, cmd, err)
+	}
+	credentialSucceeded(server, readOnly, credential)
+	getLogger().Debug("rcon query", "server", server.Name, "addr", server.Addr(),
+		"command", commandName(cmd), "credential", credential.Name, "duration", time.Since(start))
+	return result, nil
 }
 
-func QueryRconScores(server *ServerConfig, deadline time.Time) (*ServerScores, error) {
-	reader, err := rconExecute(server, deadline, "sv_cmd printstats")
+// execute sends command which doesn't have response, credential which succeeded last time is used
+func execute(server *ServerConfig, deadline time.Time, cmd string) error {
+	credentials, _ := orderCredentials(server, false)
+	credential := credentials[0]
+	reader, err := rconExecute(server, credential.Password, deadline, cmd)
+	observeQuery(server, cmd, time.Now(), err, false)
+	if err != nil {
+		return newQueryError(server, cmd, err)
+	}
+	return reader.Close()
+}
+
+func QueryRconStatus(server *ServerConfig, deadline time.Time) (*ServerStatus, error) {
+	status, err := readOnlyQuery(server, deadline, "sv_public\x00status 1", ParseStatus)
 	if err != nil {
 		return nil, err
 	}
-	defer reader.Close()
-	return ParseScores(reader)
+	status.Address = server.ResolvedAddr()
+	return status, nil
+}
+
+func QueryRconInfo(server *ServerConfig, deadline time.Time) (*ServerInfo, error) {
+	return query(server, deadline, "prvm_globalget server worldstatus", ParseServerInfo)
+}
+
+func QueryRconScores(server *ServerConfig, deadline time.Time) (*ServerScores, error) {
+	return readOnlyQuery(server, deadline, "sv_cmd printstats", ParseScores)
 }
 
 func PingServer(server *ServerConfig, deadline time.Time) (time.Duration, error) {
-	addr := net.JoinHostPort(server.Server, strconv.Itoa(server.Port))
+	var conn net.Conn
+
 	invalidDuration := time.Second * -1
-	conn, err := net.Dial("udp", addr)
+	addr, err := resolveAddr(server, deadline)
+	if err == nil {
+		conn, err = net.Dial("udp", addr)
+	}
 	if err != nil {
+		observeQuery(server, "ping", time.Now(), err, false)
 		return invalidDuration, err
 	}
 	defer conn.Close()
@@ -224,6 +432,7 @@ func PingServer(server *ServerConfig, deadline time.Time) (time.Duration, error)
 	conn.Write([]byte(PingPacket))
 	start := time.Now()
 	n, err := conn.Read(readBuffer)
+	observeQuery(server, "ping", start, err, false)
 	if err != nil {
 		return invalidDuration, err
 	}
@@ -236,16 +445,73 @@ func PingServer(server *ServerConfig, deadline time.Time) (time.Duration, error)
 }
 
 func QueryRconMemstats(server *ServerConfig, deadline time.Time) (*ServerMemstats, error) {
-	reader, err := rconExecute(server, deadline, "memstats")
-	if err != nil {
-		return nil, err
+	return query(server, deadline, "memstats", ParseMemstats)
+}
+
+// QueryCvars reads current and default values of cvars in one rcon roundtrip,
+// cvars unknown to server are missing in result
+func QueryCvars(server *ServerConfig, deadline time.Time, names ...string) (map[string]*Cvar, error) {
+	if len(names) == 0 {
+		return make(map[string]*Cvar), nil
 	}
-	defer reader.Close()
-	return ParseMemstats(reader)
+	for _, name := range names {
+		if !ValidCvarName(name) {
+			return nil, fmt.Errorf("Invalid cvar name %q", name)
+		}
+	}
+	return query(server, deadline, strings.Join(names, "\x00"), func(r io.Reader) (map[string]*Cvar, error) {
+		return ParseCvars(r, len(names))
+	})
+}
+
+// SetCvars changes cvars values, server doesn't respond to this command,
+// so changes should be verified with QueryCvars
+func SetCvars(server *ServerConfig, deadline time.Time, cvars ...Cvar) error {
+	var commands []string
+
+	for _, cvar := range cvars {
+		if !ValidCvarName(cvar.Name) {
+			return fmt.Errorf("Invalid cvar name %q", cvar.Name)
+		}
+		if !ValidCvarValue(cvar.Value) {
+			return fmt.Errorf("Invalid value for cvar %s", cvar.Name)
+		}
+		commands = append(commands, fmt.Sprintf("%s \"%s\"", cvar.Name, cvar.Value))
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	return execute(server, deadline, strings.Join(commands, "\x00"))
+}
+
+// Say sends chat message from server console
+func Say(server *ServerConfig, deadline time.Time, message string) error {
+	if !ValidCvarValue(message) {
+		return fmt.Errorf("Chat message contains invalid characters")
+	}
+	return execute(server, deadline, fmt.Sprintf("say \"%s\"", message))
 }
 
 type Retryable[T any] func(deadline time.Time) (T, error)
 
+// attemptFailed logs and counts failed attempt, only last attempt is logged as warning
+func attemptFailed(err error, attempt, retries int) {
+	var queryErr *QueryError
+
+	level := slog.LevelDebug
+	if attempt == retries {
+		level = slog.LevelWarn
+	}
+	args := []any{"attempt", attempt, "retries", retries, "error", err}
+	if errors.As(err, &queryErr) {
+		args = append(queryErr.LogAttrs(), args...)
+	}
+	getLogger().Log(context.Background(), level, "rcon query failed", args...)
+	if attempt < retries {
+		observeRetry(err)
+	}
+}
+
 func QueryWithRetries[T any](timeout time.Duration, retries int, fn Retryable[T]) (T, error) {
 	var result T
 	var err error
@@ -256,11 +522,34 @@ func QueryWithRetries[T any](timeout time.Duration, retries int, fn Retryable[T]
 		if err == nil {
 			return result, nil
 		}
-		log.Printf("rcon error: %v", err)
+		attemptFailed(err, i+1, retries)
 	}
 	return result, err
 }
 
+// CountPlayers counts bots, spectators and active players, playing bots are counted as active too
+func CountPlayers(status *ServerStatus) PlayerStats {
+	var stats PlayerStats
+
+	for _, p := range status.Players {
+		if p.IsBot {
+			stats.Bots++
+		}
+		// bots can spectate too, they are counted as spectators then
+		if p.Frags == SpectatorFrags {
+			stats.Spectators++
+		} else {
+			stats.Active++
+		}
+	}
+	return stats
+}
+
+// Humans returns number of connected players excluding bots
+func (s PlayerStats) Humans() int {
+	return s.Active - s.Bots + s.Spectators
+}
+
 func QueryServerMetrics(server ServerConfig, timeout time.Duration, retries int) (*ServerMetrics, error) {
 	var metrics ServerMetrics
 	var wg sync.WaitGroup
@@ -268,10 +557,6 @@ func QueryServerMetrics(server ServerConfig, timeout time.Duration, retries int)
 	var pingErr error
 	var memstatsErr error
 
-	metrics.PlayersInfo.Bots = 0
-	metrics.PlayersInfo.Spectators = 0
-	metrics.PlayersInfo.Active = 0
-
 	wg.Add(3)
 	go func(s *ServerConfig, retries int) {
 		defer wg.Done()
@@ -280,20 +565,10 @@ func QueryServerMetrics(server ServerConfig, timeout time.Duration, retries int)
 			status, statusErr := QueryRconStatus(s, deadline)
 			if statusErr == nil {
 				metrics.Status = status
-				for _, p := range status.Players {
-					if p.IsBot {
-						metrics.PlayersInfo.Bots++
-					}
-
-					if p.Frags == -666 {
-						metrics.PlayersInfo.Spectators++
-					} else {
-						metrics.PlayersInfo.Active++
-					}
-				}
+				metrics.PlayersInfo = CountPlayers(status)
 				return
 			}
-			log.Printf("rcon error: %v", statusErr)
+			attemptFailed(statusErr, i+1, retries)
 		}
 	}(&server, retries)
 
@@ -307,7 +582,7 @@ func QueryServerMetrics(server ServerConfig, timeout time.Duration, retries int)
 				metrics.PingSeconds = float64(d) / float64(time.Second)
 				return
 			}
-			log.Printf("ping error: %v", pingErr)
+			attemptFailed(newQueryError(s, "ping", pingErr), i+1, retries)
 		}
 	}(&server, retries)
 
@@ -320,10 +595,11 @@ func QueryServerMetrics(server ServerConfig, timeout time.Duration, retries int)
 				metrics.Memory = mem
 				return
 			}
-			log.Printf("memstats error: %v", memstatsErr)
+			attemptFailed(memstatsErr, i+1, retries)
 		}
 	}(&server, retries)
 	wg.Wait()
+	metrics.Addr = server.ResolvedAddr()
 	err := statusErr
 	if err == nil {
 		if pingErr != nil {
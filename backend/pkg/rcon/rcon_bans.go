@@ -0,0 +1,59 @@
+package rcon
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BanTypeIP   = "ip"
+	BanTypeIDFP = "idfp"
+)
+
+// ip address, ip prefix like 1.2.3 or crypto_idfp which is base64 encoded
+var banAddressRe *regexp.Regexp = regexp.MustCompile(`^[0-9A-Za-z.:+/=\[\]\-]+$`)
+
+type Ban struct {
+	ID        int     `json:"id"`
+	Address   string  `json:"address"`
+	Type      string  `json:"type"`
+	Remaining float64 `json:"remaining"`
+}
+
+func ValidBanAddress(address string) bool {
+	return banAddressRe.MatchString(address)
+}
+
+func banType(address string) string {
+	if strings.ContainsAny(address, ".:") {
+		return BanTypeIP
+	}
+	return BanTypeIDFP
+}
+
+func QueryBans(server *ServerConfig, deadline time.Time) ([]Ban, error) {
+	return query(server, deadline, "sv_cmd bans", ParseBans)
+}
+
+// AddBan bans address for duration, server doesn't acknowledge it,
+// so ban should be verified with QueryBans
+func AddBan(server *ServerConfig, deadline time.Time, address string, duration time.Duration, reason string) error {
+	if !ValidBanAddress(address) {
+		return fmt.Errorf("Invalid ban address %q", address)
+	}
+	if !ValidCvarValue(reason) {
+		return fmt.Errorf("Invalid ban reason")
+	}
+	seconds := strconv.FormatFloat(math.Ceil(duration.Seconds()), 'f', 0, 64)
+	cmd := fmt.Sprintf("sv_cmd ban %s %s \"%s\"", address, seconds, reason)
+	return execute(server, deadline, cmd)
+}
+
+// RemoveBan removes ban by id from bans list
+func RemoveBan(server *ServerConfig, deadline time.Time, id int) error {
+	return execute(server, deadline, fmt.Sprintf("sv_cmd unban %d", id))
+}
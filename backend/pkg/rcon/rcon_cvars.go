@@ -0,0 +1,51 @@
+package rcon
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var cvarNameRe *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+type Cvar struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+}
+
+func ValidCvarName(name string) bool {
+	return cvarNameRe.MatchString(name)
+}
+
+// ValidCvarValue checks that value can be safely quoted in console command
+func ValidCvarValue(value string) bool {
+	return !strings.ContainsAny(value, "\"\\;\r\n\x00")
+}
+
+func (c *Cvar) IsDefault() bool {
+	return c.Value == c.Default
+}
+
+func (c *Cvar) Int() (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(c.Value), 10, 64)
+}
+
+func (c *Cvar) Float() (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(c.Value), 64)
+}
+
+// Bool follows DarkPlaces convention where any non zero number is true
+func (c *Cvar) Bool() (bool, error) {
+	val, err := c.Float()
+	if err != nil {
+		return false, fmt.Errorf("cvar %s is not a boolean: %w", c.Name, err)
+	}
+	return val != 0, nil
+}
+
+// List splits space separated values like g_maplist
+func (c *Cvar) List() []string {
+	return strings.Fields(c.Value)
+}
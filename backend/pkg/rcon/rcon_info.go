@@ -0,0 +1,143 @@
+package rcon
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mutatorCvars maps cvars enabling gameplay mutators to names of mutators
+var mutatorCvars = map[string]string{
+	"g_instagib":               "instagib",
+	"g_overkill":               "overkill",
+	"g_nix":                    "nix",
+	"g_new_toys":               "new_toys",
+	"g_weaponarena":            "weaponarena",
+	"g_vampire":                "vampire",
+	"g_buffs":                  "buffs",
+	"g_nades":                  "nades",
+	"g_melee_only":             "melee_only",
+	"g_pinata":                 "pinata",
+	"g_midair":                 "midair",
+	"g_invincible_projectiles": "invincible_projectiles",
+	"g_rocket_flying":          "rocket_flying",
+	"g_spawn_near_teammates":   "spawn_near_teammates",
+	"g_physical_items":         "physical_items",
+}
+
+func decodeServerFlags(flags int32) ServerFlags {
+	return ServerFlags{
+		AllowFullbright:   flags&ServerFlagAllowFullbright != 0,
+		Teamplay:          flags&ServerFlagTeamplay != 0,
+		PlayerStats:       flags&ServerFlagPlayerStats != 0,
+		PlayerStatsCustom: flags&ServerFlagPlayerStatsCustom != 0,
+	}
+}
+
+func parseScoreLabel(label string) ScoreLabel {
+	var result ScoreLabel
+
+	for {
+		if strings.HasSuffix(label, "!!") {
+			result.Primary = true
+			label = label[:len(label)-2]
+		} else if strings.HasSuffix(label, "!") {
+			result.Secondary = true
+			label = label[:len(label)-1]
+		} else if strings.HasSuffix(label, "<") {
+			result.LowerIsBetter = true
+			label = label[:len(label)-1]
+		} else {
+			break
+		}
+	}
+	result.Name = label
+	return result
+}
+
+func parseScoreLabels(labels string) []ScoreLabel {
+	var result []ScoreLabel
+
+	if labels == "" {
+		return result
+	}
+	for _, label := range strings.Split(labels, ",") {
+		result = append(result, parseScoreLabel(label))
+	}
+	return result
+}
+
+// parseWorldScores decodes score string from worldstatus, it looks like
+// player labels:team labels:team:team scores:team:team scores...
+func parseWorldScores(info *ServerInfo) error {
+	parts := strings.Split(strings.TrimSpace(info.ScoreString), ":")
+	info.PlayerLabels = parseScoreLabels(parts[0])
+	if len(parts) < 2 {
+		return nil
+	}
+	info.TeamLabels = parseScoreLabels(parts[1])
+	teams := parts[2:]
+	if len(teams)%2 != 0 {
+		return fmt.Errorf("Unpaired team scores: %w", invalidInputError)
+	}
+	for i := 0; i < len(teams); i += 2 {
+		var teamScore TeamScore
+
+		team, err := strconv.Atoi(teams[i])
+		if err != nil {
+			return err
+		}
+		teamScore.Team = team
+		if teams[i+1] != "" {
+			for _, item := range strings.Split(teams[i+1], ",") {
+				val, err := strconv.ParseInt(item, 10, 64)
+				if err != nil {
+					return err
+				}
+				teamScore.Scores = append(teamScore.Scores, val)
+			}
+		}
+		info.TeamScores = append(info.TeamScores, teamScore)
+	}
+	return nil
+}
+
+// decodeWorldScores decodes score string of worldstatus, broken score string doesn't fail
+// whole info, scores are left empty then
+func decodeWorldScores(info *ServerInfo) {
+	if err := parseWorldScores(info); err != nil {
+		getLogger().Debug("Can't decode score string", "score_string", info.ScoreString, "error", err)
+		info.PlayerLabels = nil
+		info.TeamLabels = nil
+		info.TeamScores = nil
+	}
+}
+
+// mutatorsFromCvars returns sorted names of enabled mutators, mutator is disabled
+// when its cvar is empty, zero or unknown to server
+func mutatorsFromCvars(cvars map[string]*Cvar) []string {
+	mutators := []string{}
+	for name, mutator := range mutatorCvars {
+		if cvar, ok := cvars[name]; ok && cvar.Value != "" && cvar.Value != "0" {
+			mutators = append(mutators, mutator)
+		}
+	}
+	sort.Strings(mutators)
+	return mutators
+}
+
+// QueryMutators reads cvars of mutators and returns names of enabled ones
+func QueryMutators(server *ServerConfig, deadline time.Time) ([]string, error) {
+	names := make([]string, 0, len(mutatorCvars))
+	for name := range mutatorCvars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cvars, err := QueryCvars(server, deadline, names...)
+	if err != nil {
+		return nil, err
+	}
+	return mutatorsFromCvars(cvars), nil
+}
@@ -0,0 +1,64 @@
+package rcon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWorldScores(t *testing.T) {
+	info := ServerInfo{ScoreString: "score!!,caps!,captime<!:caps!!,score:5:2,91:14:1,57"}
+	err := parseWorldScores(&info)
+	if err != nil {
+		t.Error("Error during parsing ", err)
+	}
+	expectedLabels := []ScoreLabel{
+		{Name: "score", Primary: true},
+		{Name: "caps", Secondary: true},
+		{Name: "captime", Secondary: true, LowerIsBetter: true},
+	}
+	if !reflect.DeepEqual(info.PlayerLabels, expectedLabels) {
+		t.Error("Incorrect player labels ", info.PlayerLabels)
+	}
+	if len(info.TeamLabels) != 2 || info.TeamLabels[0].Name != "caps" || !info.TeamLabels[0].Primary {
+		t.Error("Incorrect team labels ", info.TeamLabels)
+	}
+	expectedTeams := []TeamScore{{5, []int64{2, 91}}, {14, []int64{1, 57}}}
+	if !reflect.DeepEqual(info.TeamScores, expectedTeams) {
+		t.Error("Incorrect team scores ", info.TeamScores)
+	}
+}
+
+func TestParseWorldScoresNoTeams(t *testing.T) {
+	info := ServerInfo{ScoreString: "score!!"}
+	err := parseWorldScores(&info)
+	if err != nil || len(info.PlayerLabels) != 1 || info.TeamScores != nil {
+		t.Error("Incorrectly parsed scores without teams ", info, err)
+	}
+	info = ServerInfo{ScoreString: "score!!:score:5"}
+	if err := parseWorldScores(&info); err == nil {
+		t.Error("Expected error for unpaired team scores")
+	}
+}
+
+func TestDecodeWorldScoresInvalid(t *testing.T) {
+	info := ServerInfo{ScoreString: "score!!:caps!!:5:x"}
+	decodeWorldScores(&info)
+	if info.PlayerLabels != nil || info.TeamLabels != nil || info.TeamScores != nil {
+		t.Error("Broken scores should be empty ", info)
+	}
+}
+
+func TestMutatorsFromCvars(t *testing.T) {
+	cvars := map[string]*Cvar{
+		"g_instagib":    {Name: "g_instagib", Value: "1"},
+		"g_overkill":    {Name: "g_overkill", Value: "0"},
+		"g_weaponarena": {Name: "g_weaponarena", Value: "devastator"},
+		"g_nix":         {Name: "g_nix", Value: ""},
+	}
+	if mutators := mutatorsFromCvars(cvars); !reflect.DeepEqual(mutators, []string{"instagib", "weaponarena"}) {
+		t.Error("Incorrect mutators ", mutators)
+	}
+	if mutators := mutatorsFromCvars(nil); !reflect.DeepEqual(mutators, []string{}) {
+		t.Error("Incorrect mutators ", mutators)
+	}
+}
@@ -67,6 +67,23 @@ var playerScores string = `:status:ctf_Spectrum_2:122
 stats dumped.
 `
 
+var cvarsRcon string = `"g_maplist" is "dance stormkeep" ["dance"]
+Unknown command "foobar"
+^3timelimit^7 is "15^7" ["20^7"] time limit in minutes
+"sv_public" is "1" ["1"]
+`
+
+var bansRcon string = `^2Listing all existing active bans:
+  #0: 127.0.0.1 is still banned for 3587.5 seconds
+  #2: 10.0.0 is still banned for 12 days
+  #3: Vq9pfDUjvdEmkKsO8whEPe/PZsU7zRgw+kJgZ5Jr7mo= is still banned for 60 seconds
+^2Done listing all active (3) bans.
+`
+
+var emptyBansRcon string = `^2Listing all existing active bans:
+  none
+`
+
 func TestParseStatusEmpty(t *testing.T) {
 	reader := strings.NewReader(emptyServer)
 	status, err := ParseStatus(reader)
@@ -124,23 +141,30 @@ func TestParseStatusFull(t *testing.T) {
 		t.Error("Incorrect number of players ", status)
 	}
 	p = status.Players[0]
-	if !p.IsBot || p.Name != "bot1" || p.Ping != 70 {
+	if !p.IsBot || p.Type != PlayerTypeBot || p.IP != "" || p.Name != "bot1" || p.Ping != 70 {
 		t.Error("Incorrectly parsed first player ", p)
 	}
 	p = status.Players[1]
-	if p.IP != "127.0.0.1:39707" || p.Name != "Player1" || p.Frags != -666 || p.Number != 2 {
+	if p.IP != "127.0.0.1" || p.Port != 39707 || p.Name != "Player1" || p.Frags != -666 || p.Number != 2 {
 		t.Error("Incorrectly parsed second player ", p)
 	}
+	if p.Type != PlayerTypeSpectator || p.Time != 5421 {
+		t.Error("Incorrectly parsed second player ", p)
+	}
+	p = status.Players[2]
+	if p.Type != PlayerTypePlayer || p.Time != 43 || p.IsBot {
+		t.Error("Incorrectly parsed 3 player ", p)
+	}
 	p = status.Players[3]
-	if p.IP != "[3b04:4c9:127:7511:8:0:0:16]:38914" || p.Name != "Player3" || p.Number != 10 {
+	if p.IP != "3b04:4c9:127:7511:8:0:0:16" || p.Port != 38914 || p.Name != "Player3" || p.Number != 10 {
 		t.Error("Incorrectly parsed 4 player ", p)
 	}
 	p = status.Players[4]
-	if p.IP != "[2001:4:211:7466:271c:2345:abcd:ef01]:56338" || p.Name != "Player4" || p.Time != "3:13:22" {
+	if p.IP != "2001:4:211:7466:271c:2345:abcd:ef01" || p.Name != "Player4" || p.Time != 11602 {
 		t.Error("Incorrectly parsed 5 player ", p)
 	}
 	p = status.Players[5]
-	if p.IP != "local" || p.PL != 0 || p.Frags != -666 || p.Name != "Player5" || p.Number != 13 {
+	if !p.IsLocal || p.IP != "" || p.PL != 0 || p.Frags != -666 || p.Name != "Player5" || p.Number != 13 {
 		t.Error("Incorrectly parsed 6 player ", p)
 	}
 }
@@ -181,6 +205,12 @@ func TestParseInfo(t *testing.T) {
 	if info.ModName != "XPM" || info.ScoreString != "goals!!:goals!!:5:0:14:0" {
 		t.Error("Incorrectly parsed ServerInfo ", info)
 	}
+	if !info.Flags.AllowFullbright || !info.Flags.Teamplay || info.Flags.PlayerStats {
+		t.Error("Incorrectly decoded server flags ", info.Flags)
+	}
+	if len(info.TeamScores) != 2 || info.TeamScores[1].Team != 14 {
+		t.Error("Incorrectly parsed team scores ", info.TeamScores)
+	}
 }
 
 func TestParseScores(t *testing.T) {
@@ -208,6 +238,55 @@ func TestParseScores(t *testing.T) {
 	}
 }
 
+func TestParseCvars(t *testing.T) {
+	reader := strings.NewReader(cvarsRcon)
+	cvars, err := ParseCvars(reader, 4)
+	if err != nil {
+		t.Error("Error during parsing ", err)
+	}
+	if len(cvars) != 3 {
+		t.Error("Incorrect number of cvars ", cvars)
+	}
+	maplist, ok := cvars["g_maplist"]
+	if !ok || !reflect.DeepEqual(maplist.List(), []string{"dance", "stormkeep"}) || maplist.Default != "dance" {
+		t.Error("Incorrectly parsed g_maplist ", maplist)
+	}
+	timelimit, ok := cvars["timelimit"]
+	if !ok || timelimit.Value != "15" || timelimit.Default != "20" || timelimit.IsDefault() {
+		t.Error("Incorrectly parsed timelimit ", timelimit)
+	}
+	if val, err := timelimit.Int(); err != nil || val != 15 {
+		t.Error("Incorrect timelimit value ", val, err)
+	}
+	public, ok := cvars["sv_public"]
+	if val, err := public.Bool(); !ok || err != nil || !val {
+		t.Error("Incorrectly parsed sv_public ", public)
+	}
+}
+
+func TestParseBans(t *testing.T) {
+	bans, err := ParseBans(strings.NewReader(bansRcon))
+	if err != nil {
+		t.Error("Error during parsing ", err)
+	}
+	if len(bans) != 3 {
+		t.Fatal("Incorrect number of bans ", bans)
+	}
+	if bans[0].ID != 0 || bans[0].Address != "127.0.0.1" || bans[0].Type != BanTypeIP || bans[0].Remaining != 3587.5 {
+		t.Error("Incorrectly parsed first ban ", bans[0])
+	}
+	if bans[1].ID != 2 || bans[1].Address != "10.0.0" || bans[1].Remaining != 12*24*60*60 {
+		t.Error("Incorrectly parsed second ban ", bans[1])
+	}
+	if bans[2].Type != BanTypeIDFP || bans[2].Address != "Vq9pfDUjvdEmkKsO8whEPe/PZsU7zRgw+kJgZ5Jr7mo=" {
+		t.Error("Incorrectly parsed third ban ", bans[2])
+	}
+	bans, err = ParseBans(strings.NewReader(emptyBansRcon))
+	if err != nil || len(bans) != 0 {
+		t.Error("Incorrectly parsed empty bans ", bans, err)
+	}
+}
+
 func FuzzParseMemstats(f *testing.F) {
 	f.Add(memstatsRcon)
 
@@ -236,6 +315,25 @@ func FuzzParseScores(f *testing.F) {
 	})
 }
 
+func FuzzParseCvars(f *testing.F) {
+	f.Add(cvarsRcon)
+
+	f.Fuzz(func(t *testing.T, in string) {
+		reader := strings.NewReader(in)
+		ParseCvars(reader, 4)
+	})
+}
+
+func FuzzParseBans(f *testing.F) {
+	f.Add(bansRcon)
+	f.Add(emptyBansRcon)
+
+	f.Fuzz(func(t *testing.T, in string) {
+		reader := strings.NewReader(in)
+		ParseBans(reader)
+	})
+}
+
 func BenchmarkParseMemstats(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		reader := strings.NewReader(memstatsRcon)
@@ -249,3 +347,19 @@ func BenchmarkParseStatus(b *testing.B) {
 		ParseStatus(strings.NewReader(fullServer))
 	}
 }
+
+func TestCountPlayers(t *testing.T) {
+	status := &ServerStatus{Players: []Player{
+		{Frags: 10, Type: PlayerTypePlayer},
+		{Frags: SpectatorFrags, Type: PlayerTypeSpectator},
+		{Frags: 3, IsBot: true, Type: PlayerTypeBot},
+		{Frags: SpectatorFrags, IsBot: true, Type: PlayerTypeBot},
+	}}
+	stats := CountPlayers(status)
+	if stats != (PlayerStats{Bots: 2, Spectators: 2, Active: 2}) {
+		t.Error("Incorrect stats ", stats)
+	}
+	if stats.Humans() != 2 {
+		t.Error("Incorrect humans count ", stats.Humans())
+	}
+}
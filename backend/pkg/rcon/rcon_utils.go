@@ -3,7 +3,10 @@ package rcon
 import (
 	"bytes"
 	"crypto/hmac"
+	"errors"
+	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/md4"
@@ -26,6 +29,8 @@ func RconNonSecurePacket(command string, password string, buf *bytes.Buffer) {
 	buf.WriteString(command)
 }
 
+// srcon packets are signed with HMAC-MD4 only, darkplaces doesn't accept other
+// algorithms, so it isn't configurable per server
 func RconSecureTimePacket(command string, password string, ts time.Time, buf *bytes.Buffer) {
 	mac := hmac.New(md4.New, []byte(password))
 	t := float64(ts.UnixNano()) / float64(time.Second/time.Nanosecond)
@@ -55,3 +60,63 @@ func RconSecureChallengePacket(Command string, Password string, Challenge []byte
 	buf.WriteString(" ")
 	buf.WriteString(Command)
 }
+
+// splitPlayerAddress splits client address from status output into ip and port,
+// address is returned as is when it can't be splitted
+func splitPlayerAddress(addr string) (string, int) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return addr, 0
+	}
+	return host, port
+}
+
+// parsePlayingTime converts connection time like 1:30:21 into seconds
+func parsePlayingTime(str string) (int64, error) {
+	var seconds int64
+
+	parts := strings.Split(strings.TrimSpace(str), ":")
+	if len(parts) > 3 {
+		return 0, errors.New("Invalid playing time")
+	}
+	for _, part := range parts {
+		val, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		seconds = seconds*60 + val
+	}
+	return seconds, nil
+}
+
+func isHexDigit(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+// StripColors removes DarkPlaces color codes like ^1 and ^xF0A, ^^ is escaped caret
+func StripColors(str string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(str); i++ {
+		if str[i] != '^' || i+1 >= len(str) {
+			b.WriteByte(str[i])
+			continue
+		}
+		next := str[i+1]
+		if next >= '0' && next <= '9' {
+			i++
+		} else if next == '^' {
+			b.WriteByte('^')
+			i++
+		} else if next == 'x' && i+4 < len(str) && isHexDigit(str[i+2]) && isHexDigit(str[i+3]) && isHexDigit(str[i+4]) {
+			i += 4
+		} else {
+			b.WriteByte(str[i])
+		}
+	}
+	return b.String()
+}
@@ -2,6 +2,9 @@ package rcon
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -34,3 +37,68 @@ func TestRconSecureTime(t *testing.T) {
 		t.Error("Incorrect result: ", b.String())
 	}
 }
+
+func TestSecretRedacted(t *testing.T) {
+	server := ServerConfig{Server: "127.0.0.1", Port: 26000, RconPassword: "s3cr3t"}
+	data, err := json.Marshal(server)
+	if err != nil {
+		t.Error("Can't marshal server config ", err)
+	}
+	if bytes.Contains(data, []byte("s3cr3t")) {
+		t.Error("Password leaked to json: ", string(data))
+	}
+	if str := fmt.Sprintf("%v %+v %#v", server, server, server); strings.Contains(str, "s3cr3t") {
+		t.Error("Password leaked to formatted output: ", str)
+	}
+}
+
+func TestSplitPlayerAddress(t *testing.T) {
+	cases := []struct {
+		addr string
+		ip   string
+		port int
+	}{
+		{"127.0.0.1:39707", "127.0.0.1", 39707},
+		{"[2001:4:211:7466:271c:2345:abcd:ef01]:56338", "2001:4:211:7466:271c:2345:abcd:ef01", 56338},
+		{"127.0.0.1", "127.0.0.1", 0},
+	}
+	for _, c := range cases {
+		ip, port := splitPlayerAddress(c.addr)
+		if ip != c.ip || port != c.port {
+			t.Error("Incorrectly splitted address ", c.addr, ip, port)
+		}
+	}
+}
+
+func TestParsePlayingTime(t *testing.T) {
+	cases := map[string]int64{
+		"0:04:22": 262,
+		"1:30:21": 5421,
+		"12:00":   720,
+		"5":       5,
+	}
+	for str, expected := range cases {
+		val, err := parsePlayingTime(str)
+		if err != nil || val != expected {
+			t.Error("Incorrectly parsed playing time ", str, val, err)
+		}
+	}
+	if _, err := parsePlayingTime("1:2:3:4"); err == nil {
+		t.Error("Expected error for invalid playing time")
+	}
+}
+
+func TestStripColors(t *testing.T) {
+	cases := map[string]string{
+		"^1Red^7 text":      "Red text",
+		"^xF0Aname^7":       "name",
+		"a^^b":              "a^b",
+		"^xZZ1 not a color": "^xZZ1 not a color",
+		"trailing^":         "trailing^",
+	}
+	for in, expected := range cases {
+		if out := StripColors(in); out != expected {
+			t.Error("Incorrectly stripped colors ", in, out)
+		}
+	}
+}
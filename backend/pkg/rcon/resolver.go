@@ -0,0 +1,196 @@
+package rcon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDNSTTL is how long resolved addresses are used before lookup is repeated
+const DefaultDNSTTL = time.Minute * 5
+
+const (
+	// dnsLookupTimeout bounds lookup, it doesn't depend on deadline of query
+	dnsLookupTimeout = time.Second * 2
+	// dnsRetryMin is delay before failed lookup of known host is repeated, it's doubled up to ttl
+	dnsRetryMin = time.Second * 10
+)
+
+// Resolver looks up addresses of host, *net.Resolver implements it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type dnsEntry struct {
+	ip      net.IP
+	expires time.Time
+	// retry is delay used after last failed lookup, it's zero after successful lookup
+	retry time.Duration
+}
+
+// dnsLookup is lookup in progress, concurrent resolves of same host wait for it
+type dnsLookup struct {
+	done chan struct{}
+	ip   net.IP
+	err  error
+}
+
+// DNSCache resolves hostnames of servers, addresses are cached for ttl and last known
+// address is used when lookup fails
+type DNSCache struct {
+	resolver Resolver
+	ttl      time.Duration
+	now      func() time.Time
+	lock     sync.Mutex
+	entries  map[string]dnsEntry
+	lookups  map[string]*dnsLookup
+}
+
+func NewDNSCache(resolver Resolver, ttl time.Duration) *DNSCache {
+	return &DNSCache{
+		resolver: resolver,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]dnsEntry),
+		lookups:  make(map[string]*dnsLookup),
+	}
+}
+
+var dnsCache atomic.Pointer[DNSCache]
+
+// SetResolver replaces resolver of server hostnames, cached addresses are dropped
+func SetResolver(resolver Resolver, ttl time.Duration) {
+	dnsCache.Store(NewDNSCache(resolver, ttl))
+}
+
+func getDNSCache() *DNSCache {
+	if cache := dnsCache.Load(); cache != nil {
+		return cache
+	}
+	dnsCache.CompareAndSwap(nil, NewDNSCache(net.DefaultResolver, DefaultDNSTTL))
+	return dnsCache.Load()
+}
+
+func cacheKey(host string, family int) string {
+	return host + "/" + strconv.Itoa(family)
+}
+
+// pickAddress returns first address of preferred family, other family is used when it's missing,
+// family 0 keeps order of resolver
+func pickAddress(addrs []net.IPAddr, family int) net.IP {
+	for _, addr := range addrs {
+		isIPv4 := addr.IP.To4() != nil
+		if family == 0 || family == 4 && isIPv4 || family == 6 && !isIPv4 {
+			return addr.IP
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs[0].IP
+	}
+	return nil
+}
+
+// Resolve returns address of host, ip addresses are returned as is. Concurrent resolves
+// of expired host share one lookup, it isn't bounded by deadline of caller
+func (c *DNSCache) Resolve(host string, family int, deadline time.Time) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	key := cacheKey(host, family)
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	if ok && c.now().Before(entry.expires) {
+		c.lock.Unlock()
+		return entry.ip, nil
+	}
+	lookup, running := c.lookups[key]
+	if !running {
+		lookup = &dnsLookup{done: make(chan struct{})}
+		c.lookups[key] = lookup
+		go c.lookup(key, host, family, lookup)
+	}
+	c.lock.Unlock()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	var err error
+	select {
+	case <-lookup.done:
+		err = lookup.err
+	case <-timer.C:
+		err = fmt.Errorf("lookup %s: %w", host, context.DeadlineExceeded)
+	}
+	if err != nil {
+		if ok {
+			observeLookup(resultStale)
+			getLogger().Warn("DNS lookup failed, last known address is used", "host", host, "ip", entry.ip.String(), "error", err)
+			return entry.ip, nil
+		}
+		observeLookup(resultError)
+		return nil, err
+	}
+	observeLookup(resultOK)
+	return lookup.ip, nil
+}
+
+// lookup resolves host with own timeout and updates entry, failed lookup of known host
+// keeps last address and delays next lookup with backoff
+func (c *DNSCache) lookup(key, host string, family int, lookup *dnsLookup) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	ip := pickAddress(addrs, family)
+	if err == nil && ip == nil {
+		err = fmt.Errorf("no addresses for %s", host)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err == nil {
+		c.entries[key] = dnsEntry{ip: ip, expires: c.now().Add(c.ttl)}
+	} else if entry, ok := c.entries[key]; ok {
+		entry.retry = min(max(entry.retry*2, dnsRetryMin), c.ttl)
+		entry.expires = c.now().Add(entry.retry)
+		c.entries[key] = entry
+	}
+	delete(c.lookups, key)
+	lookup.ip, lookup.err = ip, err
+	close(lookup.done)
+}
+
+// Cached returns last resolved address of host without lookup
+func (c *DNSCache) Cached(host string, family int) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.entries[cacheKey(host, family)].ip
+}
+
+// resolveAddr returns ip:port of server for dialing
+func resolveAddr(server *ServerConfig, deadline time.Time) (string, error) {
+	ip, err := getDNSCache().Resolve(server.Server, server.IPFamily, deadline)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", server.Server, err)
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(server.Port)), nil
+}
+
+// ResolveIP returns address of server through dns cache
+func (s *ServerConfig) ResolveIP(deadline time.Time) (net.IP, error) {
+	return getDNSCache().Resolve(s.Server, s.IPFamily, deadline)
+}
+
+// ResolvedAddr returns ip:port used for last query of server, it's empty when hostname wasn't resolved yet
+func (s *ServerConfig) ResolvedAddr() string {
+	ip := getDNSCache().Cached(s.Server, s.IPFamily)
+	if ip == nil {
+		return ""
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(s.Port))
+}
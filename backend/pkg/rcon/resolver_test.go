@@ -0,0 +1,202 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	addrs   map[string][]net.IPAddr
+	err     error
+	lookups int
+	// block delays lookups until it's closed or context is done
+	block chan struct{}
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups++
+	if r.block != nil {
+		select {
+		case <-r.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if addrs, ok := r.addrs[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{addrs: map[string][]net.IPAddr{
+		"dual.example.com": {{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("192.0.2.1")}},
+		"v6.example.com":   {{IP: net.ParseIP("2001:db8::2")}},
+		"localhost.test":   {{IP: net.ParseIP("127.0.0.1")}},
+	}}
+}
+
+func TestDNSCacheFamily(t *testing.T) {
+	cache := NewDNSCache(newFakeResolver(), time.Minute)
+	deadline := time.Now().Add(time.Second)
+	cases := []struct {
+		host     string
+		family   int
+		expected string
+	}{
+		{"dual.example.com", 0, "2001:db8::1"},
+		{"dual.example.com", 4, "192.0.2.1"},
+		{"dual.example.com", 6, "2001:db8::1"},
+		// other family is used when preferred is missing
+		{"v6.example.com", 4, "2001:db8::2"},
+		{"192.0.2.10", 6, "192.0.2.10"},
+	}
+	for _, c := range cases {
+		ip, err := cache.Resolve(c.host, c.family, deadline)
+		if err != nil || ip.String() != c.expected {
+			t.Errorf("%s/%d: expected %s, got %v %v", c.host, c.family, c.expected, ip, err)
+		}
+	}
+	if _, err := cache.Resolve("missing.example.com", 0, deadline); err == nil {
+		t.Error("Unknown host should fail")
+	}
+}
+
+func TestDNSCacheTTL(t *testing.T) {
+	now := time.Now()
+	resolver := newFakeResolver()
+	cache := NewDNSCache(resolver, time.Minute)
+	cache.now = func() time.Time { return now }
+	deadline := now.Add(time.Second)
+
+	cache.Resolve("dual.example.com", 4, deadline)
+	cache.Resolve("dual.example.com", 4, deadline)
+	if resolver.lookups != 1 {
+		t.Error("Address should be cached ", resolver.lookups)
+	}
+	now = now.Add(time.Minute * 2)
+	resolver.addrs["dual.example.com"] = []net.IPAddr{{IP: net.ParseIP("192.0.2.5")}}
+	if ip, _ := cache.Resolve("dual.example.com", 4, deadline); ip.String() != "192.0.2.5" || resolver.lookups != 2 {
+		t.Error("Expired address should be resolved again ", ip, resolver.lookups)
+	}
+
+	// DNS failure uses last known address
+	now = now.Add(time.Minute * 2)
+	resolver.err = &net.DNSError{Err: "server misbehaving", Name: "dual.example.com", IsTemporary: true}
+	if ip, err := cache.Resolve("dual.example.com", 4, deadline); err != nil || ip.String() != "192.0.2.5" {
+		t.Error("Last known address should be used ", ip, err)
+	}
+	if ip := cache.Cached("dual.example.com", 4); ip.String() != "192.0.2.5" {
+		t.Error("Incorrect cached address ", ip)
+	}
+	if _, err := cache.Resolve("v6.example.com", 0, deadline); !errors.Is(err, resolver.err) {
+		t.Error("Unknown host should fail without cache ", err)
+	}
+}
+
+func TestDNSCacheStaleBackoff(t *testing.T) {
+	now := time.Now()
+	resolver := newFakeResolver()
+	cache := NewDNSCache(resolver, time.Minute*5)
+	cache.now = func() time.Time { return now }
+
+	cache.Resolve("localhost.test", 0, time.Now().Add(time.Second))
+	now = now.Add(time.Minute * 10)
+	resolver.err = &net.DNSError{Err: "server misbehaving", Name: "localhost.test", IsTemporary: true}
+	for i := 0; i < 3; i++ {
+		if ip, err := cache.Resolve("localhost.test", 0, time.Now().Add(time.Second)); err != nil || ip.String() != "127.0.0.1" {
+			t.Fatal("Last known address should be used ", ip, err)
+		}
+	}
+	if resolver.lookups != 2 {
+		t.Error("Stale address should be reused until retry ", resolver.lookups)
+	}
+	now = now.Add(dnsRetryMin)
+	cache.Resolve("localhost.test", 0, time.Now().Add(time.Second))
+	if resolver.lookups != 3 {
+		t.Error("Lookup should be retried after backoff ", resolver.lookups)
+	}
+	// delay is doubled after next failure
+	now = now.Add(dnsRetryMin)
+	cache.Resolve("localhost.test", 0, time.Now().Add(time.Second))
+	if resolver.lookups != 3 {
+		t.Error("Backoff should be doubled ", resolver.lookups)
+	}
+}
+
+func TestDNSCacheSharedLookup(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.block = make(chan struct{})
+	cache := NewDNSCache(resolver, time.Minute)
+
+	// caller with short deadline doesn't cancel lookup
+	start := time.Now()
+	if _, err := cache.Resolve("localhost.test", 0, time.Now().Add(time.Millisecond*50)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Resolve should time out ", err)
+	}
+	if time.Since(start) > time.Millisecond*500 {
+		t.Error("Resolve should respect deadline ", time.Since(start))
+	}
+	results := make(chan net.IP, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			ip, _ := cache.Resolve("localhost.test", 0, time.Now().Add(time.Second))
+			results <- ip
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(resolver.block)
+	for i := 0; i < 3; i++ {
+		if ip := <-results; ip.String() != "127.0.0.1" {
+			t.Error("Incorrect address ", ip)
+		}
+	}
+	if resolver.lookups != 1 {
+		t.Error("Concurrent resolves should share lookup ", resolver.lookups)
+	}
+}
+
+func TestPingResolvedServer(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, XonMSS)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if bytes.Equal(buf[:n], []byte(PingPacket)) {
+				conn.WriteTo([]byte(PingResponse), addr)
+			}
+		}
+	}()
+
+	SetResolver(newFakeResolver(), time.Minute)
+	defer SetResolver(net.DefaultResolver, DefaultDNSTTL)
+	server := &ServerConfig{Server: "localhost.test", Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	if server.ResolvedAddr() != "" {
+		t.Error("Address shouldn't be resolved before query")
+	}
+	if _, err := PingServer(server, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if server.ResolvedAddr() != conn.LocalAddr().String() {
+		t.Error("Incorrect resolved address ", server.ResolvedAddr())
+	}
+
+	server.Server = "missing.example.com"
+	if _, err := PingServer(server, time.Now().Add(time.Second)); err == nil {
+		t.Error("Unresolved server should fail")
+	}
+}
@@ -13,9 +13,11 @@ interface XonPlayer {
     no: number;
     ping: number;
     pl: number;
-    time: string;
+    time: number;
     frags: number;
     name: string;
+    type: "bot" | "spectator" | "player";
+    country?: string;
     is_bot: boolean;
 }
 
@@ -31,6 +33,13 @@ interface XonServerStatus {
     players:  XonPlayer[];
 }
 
+function formatPlayingTime(seconds: number): string {
+    const hours = Math.floor(seconds / 3600);
+    const minutes = Math.floor(seconds / 60) % 60;
+    const secs = seconds % 60;
+    return `${hours}:${("0" + minutes).slice(-2)}:${("0" + secs).slice(-2)}`;
+}
+
 function getConnection(): NetworkInformation | undefined {
     return navigator.connection || navigator.mozConnection || navigator.webkitConnection;
 }
@@ -483,7 +492,7 @@ export class StatusComponent extends LitElement {
         player.ping}
                         </td>
                         <td class="col-pl">${player.pl}</td>
-                        <td class="col-time">${player.time}</td>
+                        <td class="col-time">${formatPlayingTime(player.time)}</td>
                         <td class="col-score">${player.frags === -666 ?
         html`<span class="spectator">spectator</span>`:
         html`<span class="frags">${player.frags}</span>`}